@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
 )
 
 type AppConfigHandler struct {
@@ -41,8 +42,14 @@ func (h *AppConfigHandler) GetConfig(c *gin.Context) {
 }
 
 // UpdateConfig handles PUT /api/config/:key
+// The sync filters are rejected, PUT /api/v1/sync/filters validates them.
 func (h *AppConfigHandler) UpdateConfig(c *gin.Context) {
 	key := c.Param("key")
+	if key == services.SyncFiltersConfigKey {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sync filters are updated with PUT /api/v1/sync/filters"})
+		return
+	}
+
 	var input struct {
 		Value string `json:"value" binding:"required"`
 	}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type CopyJobHandler struct {
+	copySvc *services.CopyService
+}
+
+func NewCopyJobHandler(copySvc *services.CopyService) *CopyJobHandler {
+	return &CopyJobHandler{copySvc: copySvc}
+}
+
+// StartCopy handles POST /api/v1/copies
+// The job is queued and runs in the background, poll GET /api/v1/copies/:id
+// for its progress.
+func (h *CopyJobHandler) StartCopy(c *gin.Context) {
+	var req services.CopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.copySvc.StartCopy(c.Request.Context(), req)
+	switch {
+	case errors.Is(err, services.ErrInvalidCopyJob):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// ListCopyJobs handles GET /api/v1/copies
+func (h *CopyJobHandler) ListCopyJobs(c *gin.Context) {
+	page, limit, ok := pagination(c, 20)
+	if !ok {
+		return
+	}
+
+	jobs, total, err := h.copySvc.ListCopyJobs(c.Request.Context(), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":       jobs,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetCopyJob handles GET /api/v1/copies/:id
+func (h *CopyJobHandler) GetCopyJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid copy job ID"})
+		return
+	}
+
+	job, err := h.copySvc.GetCopyJob(c.Request.Context(), uint(id))
+	if errors.Is(err, services.ErrCopyJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type DeletionHandler struct {
+	syncSvc *services.SyncService
+}
+
+func NewDeletionHandler(syncSvc *services.SyncService) *DeletionHandler {
+	return &DeletionHandler{syncSvc: syncSvc}
+}
+
+// ListPlans handles GET /api/v1/deletions
+// Plans can be narrowed down with ?source=bulk|retention.
+func (h *DeletionHandler) ListPlans(c *gin.Context) {
+	page, limit, ok := pagination(c, 20)
+	if !ok {
+		return
+	}
+	filter := repository.DeletionPlanFilter{Source: c.Query("source")}
+
+	plans, total, err := h.syncSvc.ListDeletionPlans(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plans":      plans,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// CreatePlan handles POST /api/v1/deletions
+// It plans a bulk delete as a dry run: nothing is deleted until the plan is
+// run through POST /api/v1/deletions/:id/run.
+func (h *DeletionHandler) CreatePlan(c *gin.Context) {
+	var req services.BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := h.syncSvc.PlanBulkDelete(c.Request.Context(), req)
+	if errors.Is(err, services.ErrInvalidBulkDelete) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// GetPlan handles GET /api/v1/deletions/:id
+func (h *DeletionHandler) GetPlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deletion plan ID"})
+		return
+	}
+
+	plan, err := h.syncSvc.GetDeletionPlan(c.Request.Context(), uint(id))
+	if errors.Is(err, services.ErrDeletionPlanNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// RunPlan handles POST /api/v1/deletions/:id/run
+// It deletes the planned manifests and returns the plan with the outcome of each.
+func (h *DeletionHandler) RunPlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deletion plan ID"})
+		return
+	}
+
+	plan, err := h.syncSvc.RunDeletionPlan(c.Request.Context(), uint(id))
+	switch {
+	case errors.Is(err, services.ErrDeletionPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeletionPlanRan):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeleteUnsupported):
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": services.ErrDeleteUnsupported.Error(), "plan": plan})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "plan": plan})
+	default:
+		c.JSON(http.StatusOK, plan)
+	}
+}
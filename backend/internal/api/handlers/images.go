@@ -1,18 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
 )
 
 type ImageHandler struct {
-	repo repository.ImageRepository
+	repo    repository.ImageRepository
+	syncSvc *services.SyncService
 }
 
-func NewImageHandler(repo repository.ImageRepository) *ImageHandler {
-	return &ImageHandler{repo: repo}
+func NewImageHandler(repo repository.ImageRepository, syncSvc *services.SyncService) *ImageHandler {
+	return &ImageHandler{repo: repo, syncSvc: syncSvc}
 }
 
 // ListImages handles GET /api/repositories/:name/images
@@ -43,3 +46,32 @@ func (h *ImageHandler) GetImage(c *gin.Context) {
 
 	c.JSON(http.StatusOK, image)
 }
+
+// DeleteImage handles DELETE /api/v1/repositories/:name/images/:image
+// It deletes every manifest of the image from the registry, see respondDeletion.
+func (h *ImageHandler) DeleteImage(c *gin.Context) {
+	report, err := h.syncSvc.DeleteImage(c.Request.Context(), c.Param("name"), c.Param("image"))
+	respondDeletion(c, report, err)
+}
+
+// respondDeletion writes the report of an image or namespace delete, with
+// 207 if some manifests couldn't be deleted. Nothing is deleted when the
+// registry doesn't allow it, which is answered with 405, or when protected
+// tags would go, which is answered with 403.
+func respondDeletion(c *gin.Context, report *services.DeletionReport, err error) {
+	var protected *services.ProtectedTagError
+	switch {
+	case errors.As(err, &protected):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "protectedTags": protected.Tags})
+	case errors.Is(err, services.ErrNotInRegistry):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeleteUnsupported):
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": services.ErrDeleteUnsupported.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case len(report.Failed) > 0:
+		c.JSON(http.StatusMultiStatus, report)
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type MirrorHandler struct {
+	mirrorSvc *services.MirrorService
+}
+
+func NewMirrorHandler(mirrorSvc *services.MirrorService) *MirrorHandler {
+	return &MirrorHandler{mirrorSvc: mirrorSvc}
+}
+
+// ListMirrors handles GET /api/v1/mirrors
+func (h *MirrorHandler) ListMirrors(c *gin.Context) {
+	mirrors, err := h.mirrorSvc.ListMirrors(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mirrors)
+}
+
+// GetMirror handles GET /api/v1/mirrors/:id
+// The mirror includes the digest each tag was last mirrored at and the
+// error of its last failed attempt.
+func (h *MirrorHandler) GetMirror(c *gin.Context) {
+	id, ok := mirrorID(c)
+	if !ok {
+		return
+	}
+
+	mirror, err := h.mirrorSvc.GetMirror(c.Request.Context(), id)
+	if err != nil {
+		respondMirrorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mirror)
+}
+
+// CreateMirror handles POST /api/v1/mirrors
+func (h *MirrorHandler) CreateMirror(c *gin.Context) {
+	h.saveMirror(c, 0, http.StatusCreated)
+}
+
+// UpdateMirror handles PUT /api/v1/mirrors/:id
+func (h *MirrorHandler) UpdateMirror(c *gin.Context) {
+	id, ok := mirrorID(c)
+	if !ok {
+		return
+	}
+	h.saveMirror(c, id, http.StatusOK)
+}
+
+func (h *MirrorHandler) saveMirror(c *gin.Context, id uint, status int) {
+	var req services.MirrorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mirror, err := h.mirrorSvc.SaveMirror(c.Request.Context(), id, req)
+	if err != nil {
+		respondMirrorError(c, err)
+		return
+	}
+
+	c.JSON(status, mirror)
+}
+
+// DeleteMirror handles DELETE /api/v1/mirrors/:id
+func (h *MirrorHandler) DeleteMirror(c *gin.Context) {
+	id, ok := mirrorID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mirrorSvc.DeleteMirror(c.Request.Context(), id); err != nil {
+		respondMirrorError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunMirror handles POST /api/v1/mirrors/:id/run
+// The mirror runs in the background, GET /api/v1/mirrors/:id shows how it went.
+func (h *MirrorHandler) RunMirror(c *gin.Context) {
+	id, ok := mirrorID(c)
+	if !ok {
+		return
+	}
+
+	mirror, err := h.mirrorSvc.StartMirror(c.Request.Context(), id)
+	if err != nil {
+		respondMirrorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, mirror)
+}
+
+// mirrorID parses the mirror ID of the path, responding with 400 if it is invalid
+func mirrorID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mirror ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondMirrorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMirrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMirror):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMirrorRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
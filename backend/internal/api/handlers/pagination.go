@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest page size the list endpoints return
+const maxPageLimit = 100
+
+// pagination reads the page and limit query parameters of a list endpoint.
+// It answers 400 and returns false when either is not a number in range.
+func pagination(c *gin.Context, defaultLimit int) (page, limit int, ok bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a number of at least 1"})
+		return 0, 0, false
+	}
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be a number from 1 to %d", maxPageLimit)})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
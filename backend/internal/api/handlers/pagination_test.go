@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query       string
+		page, limit int
+		ok          bool
+	}{
+		{"", 1, 20, true},
+		{"?page=3&limit=100", 3, 100, true},
+		{"?page=0", 0, 0, false},
+		{"?page=-1", 0, 0, false},
+		{"?page=two", 0, 0, false},
+		{"?limit=0", 0, 0, false},
+		{"?limit=-1", 0, 0, false},
+		{"?limit=101", 0, 0, false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/runs"+tt.query, nil)
+
+		page, limit, ok := pagination(c, 20)
+		if page != tt.page || limit != tt.limit || ok != tt.ok {
+			t.Errorf("pagination(%q) = %d, %d, %v; want %d, %d, %v", tt.query, page, limit, ok, tt.page, tt.limit, tt.ok)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("pagination(%q) answered %d, want %d", tt.query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type PromotionHandler struct {
+	syncSvc *services.SyncService
+}
+
+func NewPromotionHandler(syncSvc *services.SyncService) *PromotionHandler {
+	return &PromotionHandler{syncSvc: syncSvc}
+}
+
+// Promote handles POST /api/v1/promote
+// It tags the manifest of the source reference under targetTag, in the source
+// repository or in targetRepository of the same registry.
+func (h *PromotionHandler) Promote(c *gin.Context) {
+	var req services.PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.syncSvc.Promote(c.Request.Context(), req)
+
+	var protected *services.ProtectedTagError
+	switch {
+	case errors.As(err, &protected):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "protectedTags": protected.Tags})
+	case errors.Is(err, services.ErrInvalidPromotion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotInRegistry):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, promotion)
+	}
+}
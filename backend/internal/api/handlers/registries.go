@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type RegistryHandler struct {
+	copySvc *services.CopyService
+}
+
+func NewRegistryHandler(copySvc *services.CopyService) *RegistryHandler {
+	return &RegistryHandler{copySvc: copySvc}
+}
+
+// ListRegistries handles GET /api/v1/registries
+// Passwords are never returned.
+func (h *RegistryHandler) ListRegistries(c *gin.Context) {
+	registries, err := h.copySvc.ListRegistries(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, registries)
+}
+
+// SaveRegistry handles PUT /api/v1/registries/:name
+// It creates the registry or updates its URL and credentials. An empty
+// password keeps the stored one.
+func (h *RegistryHandler) SaveRegistry(c *gin.Context) {
+	var req services.RegistryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	registry, err := h.copySvc.SaveRegistry(c.Request.Context(), c.Param("name"), req)
+	if errors.Is(err, services.ErrInvalidRegistry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, registry)
+}
+
+// DeleteRegistry handles DELETE /api/v1/registries/:name
+func (h *RegistryHandler) DeleteRegistry(c *gin.Context) {
+	err := h.copySvc.DeleteRegistry(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, services.ErrRegistryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -3,17 +3,46 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
 )
 
 type RepositoryHandler struct {
-	repo repository.DockerRepository
+	repo    repository.DockerRepository
+	syncSvc *services.SyncService
 }
 
-func NewRepositoryHandler(repo repository.DockerRepository) *RepositoryHandler {
-	return &RepositoryHandler{repo: repo}
+func NewRepositoryHandler(repo repository.DockerRepository, syncSvc *services.SyncService) *RepositoryHandler {
+	return &RepositoryHandler{repo: repo, syncSvc: syncSvc}
+}
+
+// RedirectLegacyRootNamespace redirects requests for images of the
+// "library" namespace to RootNamespace, where images without a namespace
+// moved. A "library" namespace with images in the registry is left alone.
+func (h *RepositoryHandler) RedirectLegacyRootNamespace(c *gin.Context) {
+	if c.Param("name") != utils.LegacyRootNamespace {
+		return
+	}
+	repository, err := h.repo.GetRepository(c.Request.Context(), utils.LegacyRootNamespace)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if repository != nil && len(repository.Images) > 0 {
+		return
+	}
+
+	target := *c.Request.URL
+	legacy, root := "/repositories/"+utils.LegacyRootNamespace, "/repositories/"+utils.RootNamespace
+	target.Path = strings.Replace(target.Path, legacy, root, 1)
+	target.RawPath = strings.Replace(target.RawPath, legacy, root, 1)
+	// 308 keeps the method, so deletes and syncs are redirected too
+	c.Redirect(http.StatusPermanentRedirect, target.RequestURI())
+	c.Abort()
 }
 
 // ListRepositories handles GET /api/repositories
@@ -51,3 +80,10 @@ func (h *RepositoryHandler) GetRepository(c *gin.Context) {
 
 	c.JSON(http.StatusOK, repository)
 }
+
+// DeleteRepository handles DELETE /api/v1/repositories/:name
+// It deletes every manifest of every image in the namespace from the registry.
+func (h *RepositoryHandler) DeleteRepository(c *gin.Context) {
+	report, err := h.syncSvc.DeleteNamespace(c.Request.Context(), c.Param("name"))
+	respondDeletion(c, report, err)
+}
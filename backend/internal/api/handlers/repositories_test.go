@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+)
+
+// stubDockerRepository serves stored namespaces from a map
+type stubDockerRepository struct {
+	repository.DockerRepository
+	namespaces map[string]*models.Repository
+}
+
+func (r *stubDockerRepository) GetRepository(ctx context.Context, name string) (*models.Repository, error) {
+	return r.namespaces[name], nil
+}
+
+func TestRedirectLegacyRootNamespace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(namespaces map[string]*models.Repository) *gin.Engine {
+		r := gin.New()
+		r.UseRawPath = true
+		r.UnescapePathValues = true
+		h := NewRepositoryHandler(&stubDockerRepository{namespaces: namespaces}, nil)
+		repos := r.Group("/api/v1/repositories", h.RedirectLegacyRootNamespace)
+		repos.GET("/:name/images/:image", func(c *gin.Context) { c.String(http.StatusOK, c.Param("name")) })
+		repos.DELETE("/:name/images/:image", func(c *gin.Context) { c.String(http.StatusOK, c.Param("name")) })
+		return r
+	}
+	withLibrary := map[string]*models.Repository{
+		"library": {Name: "library", Images: []models.Image{{Name: "nginx", FullName: "library/nginx"}}},
+	}
+	// Left behind by syncs from before the root namespace
+	emptyLibrary := map[string]*models.Repository{"library": {Name: "library"}}
+
+	tests := []struct {
+		name       string
+		namespaces map[string]*models.Repository
+		method     string
+		path       string
+		status     int
+		location   string
+	}{
+		{"no library namespace", nil, http.MethodGet, "/api/v1/repositories/library/images/nginx?page=2",
+			http.StatusPermanentRedirect, "/api/v1/repositories/_/images/nginx?page=2"},
+		{"empty library namespace", emptyLibrary, http.MethodDelete, "/api/v1/repositories/library/images/nginx",
+			http.StatusPermanentRedirect, "/api/v1/repositories/_/images/nginx"},
+		{"encoded image name", nil, http.MethodGet, "/api/v1/repositories/library/images/tools%2Fcurl",
+			http.StatusPermanentRedirect, "/api/v1/repositories/_/images/tools%2Fcurl"},
+		{"library namespace in the registry", withLibrary, http.MethodGet, "/api/v1/repositories/library/images/nginx",
+			http.StatusOK, ""},
+		{"other namespace", nil, http.MethodGet, "/api/v1/repositories/team/images/app", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		newRouter(tt.namespaces).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: answered %d to %q, want %d to %q", tt.name, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
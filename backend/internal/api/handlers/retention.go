@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type RetentionHandler struct {
+	syncSvc *services.SyncService
+}
+
+func NewRetentionHandler(syncSvc *services.SyncService) *RetentionHandler {
+	return &RetentionHandler{syncSvc: syncSvc}
+}
+
+// retentionRuleRequest are the settings of a retention rule
+type retentionRuleRequest struct {
+	Namespace  string `json:"namespace" binding:"required"`
+	Image      string `json:"image"`
+	KeepLatest int    `json:"keepLatest"`
+	OlderThan  string `json:"olderThan"`
+	TagPattern string `json:"tagPattern"`
+	KeepSemver bool   `json:"keepSemver"`
+	Cron       string `json:"cron"` // Defaults to "manual"
+}
+
+// ListRules handles GET /api/v1/retention/rules
+func (h *RetentionHandler) ListRules(c *gin.Context) {
+	rules, err := h.syncSvc.ListRetentionRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// GetRule handles GET /api/v1/retention/rules/:id
+func (h *RetentionHandler) GetRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.syncSvc.GetRetentionRule(c.Request.Context(), id)
+	if err != nil {
+		respondRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateRule handles POST /api/v1/retention/rules
+func (h *RetentionHandler) CreateRule(c *gin.Context) {
+	h.saveRule(c, 0, http.StatusCreated)
+}
+
+// UpdateRule handles PUT /api/v1/retention/rules/:id
+func (h *RetentionHandler) UpdateRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+	h.saveRule(c, id, http.StatusOK)
+}
+
+func (h *RetentionHandler) saveRule(c *gin.Context, id uint, status int) {
+	var req retentionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.syncSvc.SaveRetentionRule(c.Request.Context(), id, models.RetentionRule{
+		Namespace:  req.Namespace,
+		Image:      req.Image,
+		KeepLatest: req.KeepLatest,
+		OlderThan:  req.OlderThan,
+		TagPattern: req.TagPattern,
+		KeepSemver: req.KeepSemver,
+		Cron:       req.Cron,
+	})
+	if err != nil {
+		respondRuleError(c, err)
+		return
+	}
+
+	c.JSON(status, rule)
+}
+
+// DeleteRule handles DELETE /api/v1/retention/rules/:id
+func (h *RetentionHandler) DeleteRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.syncSvc.DeleteRetentionRule(c.Request.Context(), id); err != nil {
+		respondRuleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewRule handles POST /api/v1/retention/rules/:id/preview
+// It stores what the rule would delete as a deletion plan without deleting
+// anything, the plan can be run through POST /api/v1/deletions/:id/run.
+func (h *RetentionHandler) PreviewRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.syncSvc.PreviewRetention(c.Request.Context(), id)
+	if err != nil {
+		respondRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// RunRule handles POST /api/v1/retention/rules/:id/run
+// It applies the rule right away and returns the plan with the outcome of each manifest.
+func (h *RetentionHandler) RunRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.syncSvc.RunRetention(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, services.ErrRetentionRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeleteUnsupported):
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": services.ErrDeleteUnsupported.Error(), "plan": plan})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "plan": plan})
+	default:
+		c.JSON(http.StatusOK, plan)
+	}
+}
+
+// ListRuns handles GET /api/v1/retention/runs
+// It lists the deletion plans retention rules made, ?ruleId= narrows them
+// down to one rule.
+func (h *RetentionHandler) ListRuns(c *gin.Context) {
+	page, limit, ok := pagination(c, 20)
+	if !ok {
+		return
+	}
+	ruleID, _ := strconv.ParseUint(c.Query("ruleId"), 10, 64)
+	filter := repository.DeletionPlanFilter{Source: models.DeletionSourceRetention, RuleID: uint(ruleID)}
+
+	plans, total, err := h.syncSvc.ListDeletionPlans(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":       plans,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// ruleID parses the rule ID of the path, responding with 400 if it is invalid
+func ruleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid retention rule ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondRuleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRetentionRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRetentionRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,27 +1,78 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
 	"github.com/ofkm/svelocker-ui/backend/internal/services"
 )
 
 type SyncHandler struct {
-	syncSvc *services.SyncService
+	syncSvc     *services.SyncService
+	syncRunRepo repository.SyncRunRepository
 }
 
-func NewSyncHandler(syncSvc *services.SyncService) *SyncHandler {
-	return &SyncHandler{syncSvc: syncSvc}
+func NewSyncHandler(syncSvc *services.SyncService, syncRunRepo repository.SyncRunRepository) *SyncHandler {
+	return &SyncHandler{syncSvc: syncSvc, syncRunRepo: syncRunRepo}
 }
 
 // TriggerSync handles POST /api/sync
+// It starts a sync in the background, or attaches to the one already running.
 func (h *SyncHandler) TriggerSync(c *gin.Context) {
-	if err := h.syncSvc.PerformSync(c.Request.Context()); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	job, started := h.syncSvc.StartSync(models.SyncTriggerManual)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"jobId":    job.ID,
+		"started":  started,
+		"progress": job.Progress(),
+	})
+}
+
+// GetJob handles GET /api/sync/jobs/:id
+func (h *SyncHandler) GetJob(c *gin.Context) {
+	job := h.syncSvc.GetJob(c.Param("id"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sync job not found"})
 		return
 	}
-	c.Status(http.StatusOK)
+
+	c.JSON(http.StatusOK, job.Progress())
+}
+
+// StreamJobEvents handles GET /api/sync/jobs/:id/events
+// It streams the job's progress as Server-Sent Events until the job completes.
+func (h *SyncHandler) StreamJobEvents(c *gin.Context) {
+	job := h.syncSvc.GetJob(c.Param("id"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sync job not found"})
+		return
+	}
+
+	events, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return event.Type != services.SyncEventComplete
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
 }
 
 // GetLastSync handles GET /api/sync/last
@@ -39,3 +90,203 @@ func (h *SyncHandler) GetLastSync(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"lastSync": lastSync})
 }
+
+// ListRuns handles GET /api/sync/runs
+func (h *SyncHandler) ListRuns(c *gin.Context) {
+	page, limit, ok := pagination(c, 20)
+	if !ok {
+		return
+	}
+
+	runs, total, err := h.syncRunRepo.ListRuns(c.Request.Context(), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":       runs,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetRun handles GET /api/sync/runs/:id
+func (h *SyncHandler) GetRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync run ID"})
+		return
+	}
+
+	run, err := h.syncRunRepo.GetRun(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sync run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// SyncNamespace handles POST /api/v1/repositories/:name/sync
+func (h *SyncHandler) SyncNamespace(c *gin.Context) {
+	result, err := h.syncSvc.SyncNamespace(c.Request.Context(), models.SyncTriggerManual, c.Param("name"))
+	h.respondTargeted(c, result, err)
+}
+
+// SyncImage handles POST /api/v1/repositories/:name/images/:image/sync
+func (h *SyncHandler) SyncImage(c *gin.Context) {
+	result, err := h.syncSvc.SyncImage(c.Request.Context(), models.SyncTriggerManual, c.Param("name"), c.Param("image"))
+	h.respondTargeted(c, result, err)
+}
+
+// SyncTag handles POST /api/v1/repositories/:name/images/:image/tags/:tag/sync
+func (h *SyncHandler) SyncTag(c *gin.Context) {
+	result, err := h.syncSvc.SyncTag(c.Request.Context(), models.SyncTriggerManual, c.Param("name"), c.Param("image"), c.Param("tag"))
+	h.respondTargeted(c, result, err)
+}
+
+func (h *SyncHandler) respondTargeted(c *gin.Context, result *services.SyncResult, err error) {
+	if errors.Is(err, services.ErrNotInRegistry) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if errors.Is(err, services.ErrExcludedFromSync) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetFilters handles GET /api/v1/sync/filters
+func (h *SyncHandler) GetFilters(c *gin.Context) {
+	filters, err := h.syncSvc.GetSyncFilters(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, filters)
+}
+
+// UpdateFilters handles PUT /api/v1/sync/filters
+func (h *SyncHandler) UpdateFilters(c *gin.Context) {
+	var filters services.SyncFilters
+	if err := c.ShouldBindJSON(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.syncSvc.UpdateSyncFilters(c.Request.Context(), filters); err != nil {
+		if errors.Is(err, services.ErrInvalidSyncFilters) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, filters)
+}
+
+// PreviewFilters handles POST /api/v1/sync/filters/preview
+// It shows which catalog entries the posted filters, or the stored ones when
+// the body is empty, would sync. Tags are listed with ?tags=true.
+func (h *SyncHandler) PreviewFilters(c *gin.Context) {
+	var filters services.SyncFilters
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&filters); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		stored, err := h.syncSvc.GetSyncFilters(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		filters = *stored
+	}
+
+	withTags := c.Query("tags") == "true"
+	preview, err := h.syncSvc.PreviewSyncFilters(c.Request.Context(), filters, withTags)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSyncFilters) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// ListSchedules handles GET /api/v1/sync/schedules
+func (h *SyncHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.syncSvc.ListSchedules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// UpdateGlobalSchedule handles PUT /api/v1/sync/schedules/global
+// An empty cron expression falls back to the sync interval.
+func (h *SyncHandler) UpdateGlobalSchedule(c *gin.Context) {
+	h.updateSchedule(c, "")
+}
+
+// UpdateNamespaceSchedule handles PUT /api/v1/sync/schedules/namespaces/:name
+func (h *SyncHandler) UpdateNamespaceSchedule(c *gin.Context) {
+	h.updateSchedule(c, c.Param("name"))
+}
+
+func (h *SyncHandler) updateSchedule(c *gin.Context, namespace string) {
+	var req struct {
+		Cron string `json:"cron"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.syncSvc.UpdateSchedule(c.Request.Context(), namespace, req.Cron)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteNamespaceSchedule handles DELETE /api/v1/sync/schedules/namespaces/:name
+// The namespace is synced by the global schedule again afterwards.
+func (h *SyncHandler) DeleteNamespaceSchedule(c *gin.Context) {
+	if err := h.syncSvc.DeleteSchedule(c.Request.Context(), c.Param("name")); err != nil {
+		if errors.Is(err, services.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
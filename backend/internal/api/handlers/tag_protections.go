@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+type TagProtectionHandler struct {
+	syncSvc *services.SyncService
+}
+
+func NewTagProtectionHandler(syncSvc *services.SyncService) *TagProtectionHandler {
+	return &TagProtectionHandler{syncSvc: syncSvc}
+}
+
+// ListProtections handles GET /api/v1/protections
+// Protections can be narrowed down to a namespace with ?namespace=.
+func (h *TagProtectionHandler) ListProtections(c *gin.Context) {
+	protections, err := h.syncSvc.ListTagProtections(c.Request.Context(), c.Query("namespace"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, protections)
+}
+
+// CreateProtection handles POST /api/v1/protections
+// The pattern is a regular expression that has to match the whole tag name.
+func (h *TagProtectionHandler) CreateProtection(c *gin.Context) {
+	var req struct {
+		Namespace string `json:"namespace" binding:"required"`
+		Pattern   string `json:"pattern" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	protection, err := h.syncSvc.CreateTagProtection(c.Request.Context(), req.Namespace, req.Pattern)
+	if errors.Is(err, services.ErrInvalidTagProtection) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, protection)
+}
+
+// DeleteProtection handles DELETE /api/v1/protections/:id
+func (h *TagProtectionHandler) DeleteProtection(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag protection ID"})
+		return
+	}
+
+	err = h.syncSvc.DeleteTagProtection(c.Request.Context(), uint(id))
+	if errors.Is(err, services.ErrTagProtectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListViolations handles GET /api/v1/protections/violations
+// It lists the protected tags the sync found overwritten, newest first.
+// Violations can be narrowed down to a namespace with ?namespace=.
+func (h *TagProtectionHandler) ListViolations(c *gin.Context) {
+	page, limit, ok := pagination(c, 20)
+	if !ok {
+		return
+	}
+
+	violations, total, err := h.syncSvc.ListProtectionViolations(c.Request.Context(), c.Query("namespace"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"violations": violations,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+// ListRevisions handles GET /api/v1/repositories/:name/images/:image/tags/:tag/revisions
+// It lists the digests the tag pointed to, newest first. With ?at=<RFC 3339
+// time> it returns only the revision the tag pointed to at that time.
+func (h *TagHandler) ListRevisions(c *gin.Context) {
+	ctx := c.Request.Context()
+	repoName, imageName, tagName := c.Param("name"), c.Param("image"), c.Param("tag")
+
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time, expected RFC 3339"})
+			return
+		}
+
+		revision, err := h.syncSvc.TagRevisionAt(ctx, repoName, imageName, tagName, t)
+		if errors.Is(err, services.ErrRevisionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag did not exist at that time"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, revision)
+		return
+	}
+
+	revisions, err := h.syncSvc.ListTagRevisions(ctx, repoName, imageName, tagName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// RollbackTag handles POST /api/v1/repositories/:name/images/:image/tags/:tag/rollback
+// It pushes the manifest of an earlier revision back under the tag.
+func (h *TagHandler) RollbackTag(c *gin.Context) {
+	var req struct {
+		RevisionID uint `json:"revisionId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.syncSvc.RollbackTag(c.Request.Context(), c.Param("name"), c.Param("image"), c.Param("tag"), req.RevisionID)
+
+	var protected *services.ProtectedTagError
+	switch {
+	case errors.As(err, &protected):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "protectedTags": protected.Tags})
+	case errors.Is(err, services.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRevisionUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -1,20 +1,24 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
 )
 
 type TagHandler struct {
-	repo repository.TagRepository
+	repo    repository.TagRepository
+	syncSvc *services.SyncService
 }
 
-func NewTagHandler(repo repository.TagRepository) *TagHandler {
-	return &TagHandler{repo: repo}
+func NewTagHandler(repo repository.TagRepository, syncSvc *services.SyncService) *TagHandler {
+	return &TagHandler{repo: repo, syncSvc: syncSvc}
 }
 
 // ListTags handles GET /api/repositories/:name/images/:image/tags
@@ -32,6 +36,8 @@ func (h *TagHandler) ListTags(c *gin.Context) {
 }
 
 // GetTag handles GET /api/repositories/:name/images/:image/tags/:tag
+// With ?platform=os/arch[/variant] only that platform is returned, and its
+// metadata replaces the tag's default one.
 func (h *TagHandler) GetTag(c *gin.Context) {
 	repoName := c.Param("name")
 	imageName := c.Param("image")
@@ -47,31 +53,104 @@ func (h *TagHandler) GetTag(c *gin.Context) {
 		return
 	}
 
+	if platform := c.Query("platform"); platform != "" {
+		match := findPlatform(tag.Platforms, platform)
+		if match == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Tag has no platform %s", platform)})
+			return
+		}
+		tag.Platforms = []models.TagPlatform{*match}
+		tag.Metadata = match.Metadata
+	}
+
 	c.JSON(http.StatusOK, tag)
 }
 
-// DeleteTag handles requests to delete a specific tag
-func (h *TagHandler) DeleteTag(c *gin.Context) {
-	repoName := c.Param("name")
-	imageName := c.Param("image")
-	tagName := c.Param("tag")
-
-	// Call repository method to delete the tag
-	err := h.repo.DeleteTag(c, repoName, imageName, tagName)
+// GetTagHistory handles GET /api/repositories/:name/images/:image/tags/:tag/history
+// It returns the build steps of the tag's default platform, or of the one
+// selected with ?platform=os/arch[/variant], with the layer each step created.
+func (h *TagHandler) GetTagHistory(c *gin.Context) {
+	tag, err := h.repo.GetTag(c.Request.Context(), c.Param("name"), c.Param("image"), c.Param("tag"))
 	if err != nil {
-		status := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "record not found") {
-			status = http.StatusNotFound
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	metadataID := tag.Metadata.ID
+	if platform := c.Query("platform"); platform != "" {
+		match := findPlatform(tag.Platforms, platform)
+		if match == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Tag has no platform %s", platform)})
+			return
 		}
+		metadataID = match.Metadata.ID
+	}
 
-		c.JSON(status, gin.H{
-			"error": fmt.Sprintf("Failed to delete tag: %v", err),
-		})
+	history, err := h.repo.ListHistory(c.Request.Context(), metadataID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Return success response
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Tag %s deleted successfully", tagName),
-	})
+	c.JSON(http.StatusOK, history)
+}
+
+// findPlatform returns the platform matching os/arch[/variant], without a
+// variant the first platform of that os and architecture
+func findPlatform(platforms []models.TagPlatform, spec string) *models.TagPlatform {
+	parts := strings.SplitN(spec, "/", 3)
+	for i := range platforms {
+		p := &platforms[i]
+		if p.OS != parts[0] {
+			continue
+		}
+		if len(parts) > 1 && p.Architecture != parts[1] {
+			continue
+		}
+		if len(parts) > 2 && p.Variant != parts[2] {
+			continue
+		}
+		return p
+	}
+	return nil
+}
+
+// DeleteTag handles DELETE /api/v1/repositories/:name/images/:image/tags/:tag
+// The registry deletes the manifest, not the tag, so other tags pointing to
+// the same digest go with it. Such deletes are refused with 409 unless
+// ?confirm=true is passed, and with 403 if any of the tags is protected.
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	tagName := c.Param("tag")
+	confirm := c.Query("confirm") == "true"
+
+	deletion, err := h.syncSvc.DeleteTag(c.Request.Context(), c.Param("name"), c.Param("image"), tagName, confirm)
+
+	var shared *services.SharedDigestError
+	var protected *services.ProtectedTagError
+	switch {
+	case errors.As(err, &protected):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "protectedTags": protected.Tags})
+	case errors.As(err, &shared):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      err.Error(),
+			"digest":     shared.Digest,
+			"sharedWith": shared.Tags,
+		})
+	case errors.Is(err, services.ErrNotInRegistry):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeleteUnsupported):
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": services.ErrDeleteUnsupported.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete tag: %v", err)})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message":     fmt.Sprintf("Tag %s deleted successfully", tagName),
+			"digest":      deletion.Digest,
+			"deletedTags": deletion.DeletedTags,
+		})
+	}
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ofkm/svelocker-ui/backend/internal/services"
+)
+
+// Largest notification envelope accepted from the registry
+const maxWebhookBodySize = 10 << 20
+
+type WebhookHandler struct {
+	syncSvc *services.SyncService
+	secret  string
+}
+
+func NewWebhookHandler(syncSvc *services.SyncService, secret string) *WebhookHandler {
+	return &WebhookHandler{syncSvc: syncSvc, secret: secret}
+}
+
+// RegistryEvents handles POST /api/v1/webhooks/registry
+// It receives registry notification envelopes and queues syncs for the affected tags.
+func (h *WebhookHandler) RegistryEvents(c *gin.Context) {
+	if h.secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Registry webhooks are disabled, set REGISTRY_WEBHOOK_SECRET to enable them"})
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook secret"})
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(c.ContentType())
+	if err != nil || mediaType != services.RegistryEventsMediaType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Expected " + services.RegistryEventsMediaType})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize)
+
+	var envelope services.RegistryEnvelope
+	if err := c.ShouldBindJSON(&envelope); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event envelope: " + err.Error()})
+		return
+	}
+
+	result, err := h.syncSvc.HandleRegistryEvents(c.Request.Context(), envelope.Events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, result)
+}
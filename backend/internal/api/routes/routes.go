@@ -13,14 +13,26 @@ func SetupRoutes(
 	dockerRepo repository.DockerRepository,
 	imageRepo repository.ImageRepository,
 	tagRepo repository.TagRepository,
+	syncRunRepo repository.SyncRunRepository,
 	syncSvc *services.SyncService,
+	copySvc *services.CopyService,
+	mirrorSvc *services.MirrorService,
+	webhookSecret string,
 ) {
 	// Create handlers with their specific repositories
-	repoHandler := handlers.NewRepositoryHandler(dockerRepo)
-	imageHandler := handlers.NewImageHandler(imageRepo)
-	tagHandler := handlers.NewTagHandler(tagRepo)
+	repoHandler := handlers.NewRepositoryHandler(dockerRepo, syncSvc)
+	imageHandler := handlers.NewImageHandler(imageRepo, syncSvc)
+	tagHandler := handlers.NewTagHandler(tagRepo, syncSvc)
 	configHandler := handlers.NewAppConfigHandler(configRepo)
-	syncHandler := handlers.NewSyncHandler(syncSvc)
+	syncHandler := handlers.NewSyncHandler(syncSvc, syncRunRepo)
+	webhookHandler := handlers.NewWebhookHandler(syncSvc, webhookSecret)
+	deletionHandler := handlers.NewDeletionHandler(syncSvc)
+	retentionHandler := handlers.NewRetentionHandler(syncSvc)
+	protectionHandler := handlers.NewTagProtectionHandler(syncSvc)
+	promotionHandler := handlers.NewPromotionHandler(syncSvc)
+	registryHandler := handlers.NewRegistryHandler(copySvc)
+	copyJobHandler := handlers.NewCopyJobHandler(copySvc)
+	mirrorHandler := handlers.NewMirrorHandler(mirrorSvc)
 
 	// API v1 group
 	v1 := r.Group("/api/v1")
@@ -38,22 +50,108 @@ func SetupRoutes(
 		{
 			sync.POST("", syncHandler.TriggerSync)
 			sync.GET("/last", syncHandler.GetLastSync)
+			sync.GET("/runs", syncHandler.ListRuns)
+			sync.GET("/runs/:id", syncHandler.GetRun)
+			sync.GET("/jobs/:id", syncHandler.GetJob)
+			sync.GET("/jobs/:id/events", syncHandler.StreamJobEvents)
+			sync.GET("/filters", syncHandler.GetFilters)
+			sync.PUT("/filters", syncHandler.UpdateFilters)
+			sync.POST("/filters/preview", syncHandler.PreviewFilters)
+			sync.GET("/schedules", syncHandler.ListSchedules)
+			sync.PUT("/schedules/global", syncHandler.UpdateGlobalSchedule)
+			sync.PUT("/schedules/namespaces/:name", syncHandler.UpdateNamespaceSchedule)
+			sync.DELETE("/schedules/namespaces/:name", syncHandler.DeleteNamespaceSchedule)
+		}
+
+		// Deletion plan routes, for bulk deletes and retention previews
+		deletions := v1.Group("/deletions")
+		{
+			deletions.GET("", deletionHandler.ListPlans)
+			deletions.POST("", deletionHandler.CreatePlan)
+			deletions.GET("/:id", deletionHandler.GetPlan)
+			deletions.POST("/:id/run", deletionHandler.RunPlan)
+		}
+
+		// Retention routes
+		retention := v1.Group("/retention")
+		{
+			retention.GET("/rules", retentionHandler.ListRules)
+			retention.POST("/rules", retentionHandler.CreateRule)
+			retention.GET("/rules/:id", retentionHandler.GetRule)
+			retention.PUT("/rules/:id", retentionHandler.UpdateRule)
+			retention.DELETE("/rules/:id", retentionHandler.DeleteRule)
+			retention.POST("/rules/:id/preview", retentionHandler.PreviewRule)
+			retention.POST("/rules/:id/run", retentionHandler.RunRule)
+			retention.GET("/runs", retentionHandler.ListRuns)
+		}
+
+		// Tag protection routes
+		protections := v1.Group("/protections")
+		{
+			protections.GET("", protectionHandler.ListProtections)
+			protections.POST("", protectionHandler.CreateProtection)
+			protections.DELETE("/:id", protectionHandler.DeleteProtection)
+			protections.GET("/violations", protectionHandler.ListViolations)
+		}
+
+		// Promote an image to another tag or repository of the registry
+		v1.POST("/promote", promotionHandler.Promote)
+
+		// Registries images can be copied between, besides the local one
+		registries := v1.Group("/registries")
+		{
+			registries.GET("", registryHandler.ListRegistries)
+			registries.PUT("/:name", registryHandler.SaveRegistry)
+			registries.DELETE("/:name", registryHandler.DeleteRegistry)
+		}
+
+		// Copy job routes
+		copies := v1.Group("/copies")
+		{
+			copies.POST("", copyJobHandler.StartCopy)
+			copies.GET("", copyJobHandler.ListCopyJobs)
+			copies.GET("/:id", copyJobHandler.GetCopyJob)
+		}
+
+		// Mirror routes, for upstream images kept in the local registry
+		mirrors := v1.Group("/mirrors")
+		{
+			mirrors.GET("", mirrorHandler.ListMirrors)
+			mirrors.POST("", mirrorHandler.CreateMirror)
+			mirrors.GET("/:id", mirrorHandler.GetMirror)
+			mirrors.PUT("/:id", mirrorHandler.UpdateMirror)
+			mirrors.DELETE("/:id", mirrorHandler.DeleteMirror)
+			mirrors.POST("/:id/run", mirrorHandler.RunMirror)
+		}
+
+		// Webhook routes
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("/registry", webhookHandler.RegistryEvents)
 		}
 
 		// Repository routes
-		repos := v1.Group("/repositories")
+		repos := v1.Group("/repositories", repoHandler.RedirectLegacyRootNamespace)
 		{
 			repos.GET("", repoHandler.ListRepositories)
 			repos.GET("/:name", repoHandler.GetRepository)
+			repos.DELETE("/:name", repoHandler.DeleteRepository)
+			repos.POST("/:name/sync", syncHandler.SyncNamespace)
 
 			// Image routes
 			repos.GET("/:name/images", imageHandler.ListImages)
 			repos.GET("/:name/images/:image", imageHandler.GetImage)
+			repos.DELETE("/:name/images/:image", imageHandler.DeleteImage)
+			repos.POST("/:name/images/:image/sync", syncHandler.SyncImage)
 
 			// Tag routes
 			repos.GET("/:name/images/:image/tags", tagHandler.ListTags)
 			repos.GET("/:name/images/:image/tags/:tag", tagHandler.GetTag)
+			repos.GET("/:name/images/:image/tags/:tag/history", tagHandler.GetTagHistory)
+			repos.GET("/:name/images/:image/tags/:tag/revisions", tagHandler.ListRevisions)
+			repos.POST("/:name/images/:image/tags/:tag/rollback", tagHandler.RollbackTag)
 			repos.DELETE("/:name/images/:image/tags/:tag", tagHandler.DeleteTag)
+			repos.POST("/:name/images/:image/tags/:tag/sync", syncHandler.SyncTag)
 		}
 	}
 }
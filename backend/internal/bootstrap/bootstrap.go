@@ -13,14 +13,24 @@ import (
 
 // Application represents the bootstrapped application
 type Application struct {
-	Config     *config.AppConfig
-	DB         *gorm.DB
-	Router     *gin.Engine
-	ConfigRepo repository.ConfigRepository
-	DockerRepo repository.DockerRepository
-	ImageRepo  repository.ImageRepository
-	TagRepo    repository.TagRepository
-	SyncSvc    *services.SyncService
+	Config            *config.AppConfig
+	DB                *gorm.DB
+	Router            *gin.Engine
+	ConfigRepo        repository.ConfigRepository
+	DockerRepo        repository.DockerRepository
+	ImageRepo         repository.ImageRepository
+	TagRepo           repository.TagRepository
+	SyncRunRepo       repository.SyncRunRepository
+	SyncScheduleRepo  repository.SyncScheduleRepository
+	DeletionPlanRepo  repository.DeletionPlanRepository
+	RetentionRuleRepo repository.RetentionRuleRepository
+	TagProtectionRepo repository.TagProtectionRepository
+	RegistryRepo      repository.RegistryRepository
+	CopyJobRepo       repository.CopyJobRepository
+	MirrorRepo        repository.MirrorRepository
+	SyncSvc           *services.SyncService
+	CopySvc           *services.CopyService
+	MirrorSvc         *services.MirrorService
 }
 
 // Bootstrap initializes the application
@@ -47,6 +57,16 @@ func Bootstrap(ctx context.Context) (*Application, error) {
 		return nil, err
 	}
 
+	// Initialize copy service
+	if err := app.initCopyService(ctx); err != nil {
+		return nil, err
+	}
+
+	// Initialize mirror service
+	if err := app.initMirrorService(ctx); err != nil {
+		return nil, err
+	}
+
 	// Initialize router and middleware
 	if err := app.initRouter(); err != nil {
 		return nil, err
@@ -56,6 +76,12 @@ func Bootstrap(ctx context.Context) (*Application, error) {
 }
 
 func (app *Application) Close() error {
+	if app.MirrorSvc != nil {
+		app.MirrorSvc.Stop()
+	}
+	if app.CopySvc != nil {
+		app.CopySvc.Stop()
+	}
 	if app.SyncSvc != nil {
 		app.SyncSvc.Stop()
 	}
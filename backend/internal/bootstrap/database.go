@@ -1,12 +1,16 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	gormrepo "github.com/ofkm/svelocker-ui/backend/internal/repository/gorm"
+	"gorm.io/gorm"
 )
 
 // Current DB schema version
@@ -45,7 +49,10 @@ func (app *Application) initDatabase() error {
 					return fmt.Errorf("failed to rename incompatible database: %w", err)
 				}
 			} else {
-				// Database is compatible, use it
+				// Database is compatible, apply additive schema changes and use it
+				if err := migrateDatabase(tempDB); err != nil {
+					return err
+				}
 				app.DB = tempDB
 				return nil
 			}
@@ -80,23 +87,97 @@ func (app *Application) initDatabase() error {
 	}
 
 	// Auto-migrate database schemas
+	if err := migrateDatabase(db); err != nil {
+		return err
+	}
+
+	// Set the database version in the new database
+	versionRecord := models.AppConfig{Key: "db_version", Value: DB_VERSION}
+	if result := db.Where("key = ?", "db_version").FirstOrCreate(&versionRecord); result.Error != nil {
+		return fmt.Errorf("failed to set database version: %w", result.Error)
+	}
+
+	app.DB = db
+	return nil
+}
+
+// migrateDatabase creates missing tables and columns for all models
+func migrateDatabase(db *gorm.DB) error {
+	removed, err := removeDuplicateImages(db)
+	if err != nil {
+		return err
+	}
 	if err := db.AutoMigrate(
 		&models.AppConfig{},
 		&models.Repository{},
 		&models.Image{},
 		&models.Tag{},
 		&models.TagMetadata{},
+		&models.TagPlatform{},
+		&models.TagRevision{},
 		&models.ImageLayer{},
+		&models.HistoryEntry{},
+		&models.SyncRun{},
+		&models.SyncRunError{},
+		&models.SyncSchedule{},
+		&models.DeletionPlan{},
+		&models.DeletionPlanItem{},
+		&models.RetentionRule{},
+		&models.TagProtection{},
+		&models.ProtectionViolation{},
+		&models.Registry{},
+		&models.CopyJob{},
+		&models.Mirror{},
+		&models.MirroredTag{},
 	); err != nil {
 		return fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
+	if removed {
+		return removeOrphanedTags(db)
+	}
+	return nil
+}
 
-	// Set the database version in the new database
-	versionRecord := models.AppConfig{Key: "db_version", Value: DB_VERSION}
-	if result := db.Where("key = ?", "db_version").FirstOrCreate(&versionRecord); result.Error != nil {
-		return fmt.Errorf("failed to set database version: %w", result.Error)
+// removeDuplicateImages makes the registry paths of images unique, so the
+// unique index on them can be created in databases from before it. Soft
+// deleted images go, and of images synced from the same path the one synced
+// last is kept. It reports whether any were removed.
+func removeDuplicateImages(db *gorm.DB) (bool, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Image{}) || migrator.HasIndex(&models.Image{}, "FullName") {
+		return false, nil
 	}
 
-	app.DB = db
+	result := db.Unscoped().
+		Where("deleted_at IS NOT NULL OR EXISTS (?)", db.Unscoped().Table("images AS other").Select("1").
+			Where("other.full_name = images.full_name AND other.deleted_at IS NULL").
+			Where("other.last_synced > images.last_synced OR (other.last_synced = images.last_synced AND other.id > images.id)")).
+		Delete(&models.Image{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to remove duplicate images: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Removed %d deleted or duplicate images before indexing their paths", result.RowsAffected)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// removeOrphanedTags removes the tags of images that no longer exist, along
+// with their metadata
+func removeOrphanedTags(db *gorm.DB) error {
+	var ids []uint
+	err := db.Unscoped().Model(&models.Tag{}).
+		Where("image_id NOT IN (?)", db.Unscoped().Model(&models.Image{}).Select("id")).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned tags: %w", err)
+	}
+
+	tagRepo := gormrepo.NewTagRepository(db)
+	for _, id := range ids {
+		if err := tagRepo.RemoveTag(context.Background(), id); err != nil {
+			return fmt.Errorf("failed to remove orphaned tag %d: %w", id, err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,84 @@
+package bootstrap
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/config"
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// legacyImage is an image as stored before registry paths were unique
+type legacyImage struct {
+	gorm.Model
+	RepositoryID uint
+	Name         string
+	FullName     string
+	LastSynced   time.Time
+}
+
+func (legacyImage) TableName() string { return "images" }
+
+func TestMigrateDatabaseRemovesDuplicateImages(t *testing.T) {
+	dbConfig := &config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}
+	db, err := dbConfig.Connect("error")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&legacyImage{}, &models.Tag{}); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	synced := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	images := []legacyImage{
+		{Name: "nginx", FullName: "nginx", LastSynced: synced},
+		// The "library" namespace of top-level images before they moved to "_"
+		{Name: "nginx", FullName: "nginx", LastSynced: synced.Add(time.Hour)},
+		{Name: "app", FullName: "team/app", LastSynced: synced},
+		{Name: "app", FullName: "team/app", LastSynced: synced},
+		{Name: "web", FullName: "team/web", LastSynced: synced},
+		{Name: "old", FullName: "team/web", LastSynced: synced.Add(time.Hour)},
+	}
+	if err := db.Create(&images).Error; err != nil {
+		t.Fatalf("failed to create images: %v", err)
+	}
+	if err := db.Delete(&images[5]).Error; err != nil {
+		t.Fatalf("failed to soft delete image: %v", err)
+	}
+	for _, image := range images {
+		if err := db.Create(&models.Tag{ImageID: image.ID, Name: "latest"}).Error; err != nil {
+			t.Fatalf("failed to create tag: %v", err)
+		}
+	}
+
+	if err := migrateDatabase(db); err != nil {
+		t.Fatalf("migrateDatabase failed: %v", err)
+	}
+
+	var kept []uint
+	if err := db.Unscoped().Model(&models.Image{}).Order("id").Pluck("id", &kept).Error; err != nil {
+		t.Fatalf("failed to list images: %v", err)
+	}
+	want := []uint{images[1].ID, images[3].ID, images[4].ID}
+	if !slices.Equal(kept, want) {
+		t.Errorf("kept images %v, want %v", kept, want)
+	}
+	var tagImages []uint
+	if err := db.Unscoped().Model(&models.Tag{}).Order("image_id").Pluck("image_id", &tagImages).Error; err != nil {
+		t.Fatalf("failed to list tags: %v", err)
+	}
+	if !slices.Equal(tagImages, want) {
+		t.Errorf("tags are left of images %v, want %v", tagImages, want)
+	}
+	if !db.Migrator().HasIndex(&models.Image{}, "FullName") {
+		t.Error("registry paths of images aren't indexed")
+	}
+}
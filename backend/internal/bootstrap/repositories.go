@@ -12,6 +12,14 @@ func (app *Application) initRepositories(ctx context.Context) error {
 	app.DockerRepo = gorm.NewDockerRepository(app.DB)
 	app.ImageRepo = gorm.NewImageRepository(app.DB)
 	app.TagRepo = gorm.NewTagRepository(app.DB)
+	app.SyncRunRepo = gorm.NewSyncRunRepository(app.DB)
+	app.SyncScheduleRepo = gorm.NewSyncScheduleRepository(app.DB)
+	app.DeletionPlanRepo = gorm.NewDeletionPlanRepository(app.DB)
+	app.RetentionRuleRepo = gorm.NewRetentionRuleRepository(app.DB)
+	app.TagProtectionRepo = gorm.NewTagProtectionRepository(app.DB)
+	app.RegistryRepo = gorm.NewRegistryRepository(app.DB)
+	app.CopyJobRepo = gorm.NewCopyJobRepository(app.DB)
+	app.MirrorRepo = gorm.NewMirrorRepository(app.DB)
 
 	if err := app.ConfigRepo.Update(ctx, "registry_url", app.Config.Registry.URL); err != nil {
 		return err
@@ -18,6 +18,11 @@ func (app *Application) initRouter() error {
 	// Create Gin router
 	r := gin.Default()
 
+	// Image names may contain slashes (team/project/service has the image name
+	// project/service), clients send them URL-encoded as a single path segment
+	r.UseRawPath = true
+	r.UnescapePathValues = true
+
 	// Set up CORS middleware
 	r.Use(func(c *gin.Context) {
 
@@ -35,8 +40,8 @@ func (app *Application) initRouter() error {
 		c.Next()
 	})
 
-	// Set up routes with the repositories and sync service
-	routes.SetupRoutes(r, app.ConfigRepo, app.DockerRepo, app.ImageRepo, app.TagRepo, app.SyncSvc)
+	// Set up routes with the repositories and services
+	routes.SetupRoutes(r, app.ConfigRepo, app.DockerRepo, app.ImageRepo, app.TagRepo, app.SyncRunRepo, app.SyncSvc, app.CopySvc, app.MirrorSvc, app.Config.Registry.WebhookSecret)
 
 	app.Router = r
 	return nil
@@ -13,9 +13,17 @@ func (app *Application) initSyncService(ctx context.Context) error {
 		app.ImageRepo,
 		app.TagRepo,
 		app.ConfigRepo,
+		app.SyncRunRepo,
+		app.SyncScheduleRepo,
+		app.DeletionPlanRepo,
+		app.RetentionRuleRepo,
+		app.TagProtectionRepo,
 		app.Config.Registry.URL,
 		app.Config.Registry.Username,
 		app.Config.Registry.Password,
+		app.Config.Sync.RepositoryWorkers,
+		app.Config.Sync.TagWorkers,
+		app.Config.Sync.RunRetention,
 	)
 
 	// Start the sync service
@@ -25,3 +33,27 @@ func (app *Application) initSyncService(ctx context.Context) error {
 
 	return nil
 }
+
+func (app *Application) initCopyService(ctx context.Context) error {
+	// Copies get their own client for the local registry
+	registry := services.NewRegistryClient(
+		app.Config.Registry.URL,
+		app.Config.Registry.Username,
+		app.Config.Registry.Password,
+	)
+	app.CopySvc = services.NewCopyService(app.RegistryRepo, app.CopyJobRepo, registry, app.SyncSvc)
+
+	return app.CopySvc.Start(ctx)
+}
+
+func (app *Application) initMirrorService(ctx context.Context) error {
+	// Mirrors push into the local registry with a client of their own too
+	registry := services.NewRegistryClient(
+		app.Config.Registry.URL,
+		app.Config.Registry.Username,
+		app.Config.Registry.Password,
+	)
+	app.MirrorSvc = services.NewMirrorService(app.MirrorRepo, registry, app.SyncSvc)
+
+	return app.MirrorSvc.Start(ctx)
+}
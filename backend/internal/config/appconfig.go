@@ -23,10 +23,11 @@ type ServerConfig struct {
 }
 
 type RegistryConfig struct {
-	URL      string
-	Name     string
-	Username string
-	Password string
+	URL           string
+	Name          string
+	Username      string
+	Password      string
+	WebhookSecret string // Shared secret of registry notifications, webhooks are disabled when empty
 }
 
 type LoggingConfig struct {
@@ -34,7 +35,10 @@ type LoggingConfig struct {
 }
 
 type SyncConfig struct {
-	Interval int // Interval in minutes
+	Interval          int // Interval in minutes
+	RepositoryWorkers int // Repositories synced in parallel
+	TagWorkers        int // Tags synced in parallel across all repositories
+	RunRetention      int // Number of full and of targeted sync runs kept in the history
 }
 
 // NewAppConfig creates a new application configuration
@@ -49,16 +53,20 @@ func NewAppConfig() (*AppConfig, error) {
 			Path: getEnv("DB_PATH", "data/svelockerui.db"),
 		},
 		Registry: RegistryConfig{
-			URL:      getEnv("PUBLIC_REGISTRY_URL", "http://localhost:5000"),
-			Name:     getEnv("PUBLIC_REGISTRY_NAME", "Local Registry"),
-			Username: getEnv("REGISTRY_USERNAME", ""),
-			Password: getEnv("REGISTRY_PASSWORD", ""),
+			URL:           getEnv("PUBLIC_REGISTRY_URL", "http://localhost:5000"),
+			Name:          getEnv("PUBLIC_REGISTRY_NAME", "Local Registry"),
+			Username:      getEnv("REGISTRY_USERNAME", ""),
+			Password:      getEnv("REGISTRY_PASSWORD", ""),
+			WebhookSecret: getEnv("REGISTRY_WEBHOOK_SECRET", ""),
 		},
 		Logging: LoggingConfig{
 			Level: getEnv("PUBLIC_LOG_LEVEL", "INFO"),
 		},
 		Sync: SyncConfig{
-			Interval: 5, // Default to 5 minutes, will be overridden by database value
+			Interval:          5, // Default to 5 minutes, will be overridden by database value
+			RepositoryWorkers: getEnvAsInt("SYNC_REPOSITORY_WORKERS", 4),
+			TagWorkers:        getEnvAsInt("SYNC_TAG_WORKERS", 8),
+			RunRetention:      getEnvAsInt("SYNC_RUN_RETENTION", 100),
 		},
 	}, nil
 }
@@ -86,6 +94,10 @@ func (c *AppConfig) Validate() error {
 		return fmt.Errorf("registry URL is required")
 	}
 
+	if c.Sync.RepositoryWorkers < 1 || c.Sync.TagWorkers < 1 {
+		return fmt.Errorf("sync worker counts must be at least 1")
+	}
+
 	dbDir := filepath.Dir(c.Database.Path)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return fmt.Errorf("failed to create database directory: %w", err)
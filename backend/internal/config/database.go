@@ -52,7 +52,8 @@ func (c *DatabaseConfig) Connect(logLevel string) (*gorm.DB, error) {
 		config.Logger = logger.Default.LogMode(logger.Silent) // Disable logging in production
 	}
 
-	db, err := gorm.Open(sqlite.Open(c.Path), config)
+	// Wait for locks instead of failing immediately, the sync writes from several workers
+	db, err := gorm.Open(sqlite.Open(c.Path+"?_busy_timeout=5000"), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
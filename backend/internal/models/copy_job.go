@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status of a copy job
+const (
+	CopyJobStatusQueued    = "queued"
+	CopyJobStatusRunning   = "running"
+	CopyJobStatusSucceeded = "succeeded"
+	CopyJobStatusPartial   = "partial" // Finished, but some tags failed
+	CopyJobStatusFailed    = "failed"
+)
+
+// CopyJob copies a tag, or every tag of a repository, from one registry to
+// another. Registries are referenced by name, see LocalRegistryName.
+type CopyJob struct {
+	gorm.Model
+	SourceRegistry   string     `json:"sourceRegistry"`
+	SourceRepository string     `json:"sourceRepository"`
+	Tag              string     `json:"tag,omitempty"` // Empty copies the whole repository
+	TargetRegistry   string     `json:"targetRegistry"`
+	TargetRepository string     `json:"targetRepository"`
+	Status           string     `json:"status"`
+	TagsCopied       int        `json:"tagsCopied"`
+	TagsSkipped      int        `json:"tagsSkipped"` // The target already had the same manifest
+	BlobsCopied      int        `json:"blobsCopied"`
+	BlobsSkipped     int        `json:"blobsSkipped"` // The target already had the blob
+	BytesTransferred int64      `json:"bytesTransferred"`
+	StartedAt        *time.Time `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt"`
+	Error            string     `json:"error,omitempty" gorm:"type:text"`        // Error that aborted the whole job
+	Errors           []string   `json:"errors,omitempty" gorm:"serializer:json"` // Tags that failed, as "tag: message"
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// What created a deletion plan
+const (
+	DeletionSourceBulk      = "bulk"
+	DeletionSourceRetention = "retention"
+)
+
+// Status of a deletion plan
+const (
+	DeletionPlanStatusPlanned   = "planned" // Dry run, nothing was deleted yet
+	DeletionPlanStatusRunning   = "running"
+	DeletionPlanStatusSucceeded = "succeeded"
+	DeletionPlanStatusPartial   = "partial" // Finished, but some manifests were skipped or failed
+	DeletionPlanStatusFailed    = "failed"
+)
+
+// Status of a single manifest of a deletion plan
+const (
+	DeletionItemPlanned    = "planned"
+	DeletionItemShared     = "shared"     // Other tags point to the manifest and deleting them wasn't confirmed
+	DeletionItemReferenced = "referenced" // The index of a kept multi-arch tag lists the manifest
+	DeletionItemProtected  = "protected"  // A protected tag points to the manifest
+	DeletionItemDeleted    = "deleted"
+	DeletionItemChanged    = "changed" // Tags were moved since planning, the manifest was left alone
+	DeletionItemFailed     = "failed"
+	DeletionItemNotFound   = "notFound" // The tag doesn't exist in the registry
+)
+
+// DeletionPlan is a set of manifests to delete from the registry. It is
+// stored as a dry run first so it can be reviewed before it runs.
+type DeletionPlan struct {
+	gorm.Model
+	Source        string             `json:"source"`
+	RuleID        uint               `json:"ruleId,omitempty" gorm:"index"` // Retention rule the plan was made for
+	Status        string             `json:"status"`
+	ConfirmShared bool               `json:"confirmShared"`   // Whether tags sharing a manifest with a selected tag are deleted too
+	BytesFreed    int64              `json:"bytesFreed"`      // Estimated size of the layers garbage collection can reclaim afterwards
+	StartedAt     *time.Time         `json:"startedAt"`       // When the plan was run
+	FinishedAt    *time.Time         `json:"finishedAt"`      // When the plan finished running
+	Error         string             `json:"error,omitempty"` // Error that aborted the whole run
+	Items         []DeletionPlanItem `json:"items,omitempty" gorm:"foreignKey:DeletionPlanID"`
+}
+
+// DeletionPlanItem is a manifest of a deletion plan with the tags pointing to it
+type DeletionPlanItem struct {
+	gorm.Model
+	DeletionPlanID uint     `json:"deletionPlanId" gorm:"index"`
+	Namespace      string   `json:"namespace"`
+	Image          string   `json:"image"`
+	Repository     string   `json:"repository"` // Registry path of the image
+	Digest         string   `json:"digest,omitempty"`
+	Tags           []string `json:"tags" gorm:"serializer:json"`                 // Selected tags pointing to the manifest
+	SharedWith     []string `json:"sharedWith,omitempty" gorm:"serializer:json"` // Other tags pointing to the manifest
+	Size           int64    `json:"size"`                                        // Estimated size of the layers only this manifest uses
+	Status         string   `json:"status"`
+	Error          string   `json:"error,omitempty" gorm:"type:text"`
+}
@@ -1,15 +1,20 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Image represents a Docker image in a repository
 type Image struct {
 	gorm.Model
-	RepositoryID uint   `json:"repositoryId"`
-	Name         string `json:"name"`
-	FullName     string `json:"fullName"`
-	PullCount    int    `json:"pullCount"`
-	Tags         []Tag  `json:"tags,omitempty" gorm:"foreignKey:ImageID"`
+	RepositoryID uint      `json:"repositoryId"`
+	Name         string    `json:"name"`
+	FullName     string    `json:"fullName" gorm:"uniqueIndex"` // Exact path in the registry catalog
+	PullCount    int       `json:"pullCount"`
+	LastSynced   time.Time `json:"lastSynced"`
+	Tags         []Tag     `json:"tags,omitempty" gorm:"foreignKey:ImageID"`
 }
 
 // ImageLayer represents a layer in a Docker image
@@ -19,3 +24,20 @@ type ImageLayer struct {
 	Size          int64  `json:"size"`
 	Digest        string `json:"digest"`
 }
+
+// HistoryEntry is a build step of an image. Steps that created a layer are
+// paired with its digest and size.
+type HistoryEntry struct {
+	gorm.Model
+	TagMetadataID uint   `json:"tagMetadataId" gorm:"index"`
+	Step          int    `json:"step"`
+	Created       string `json:"created,omitempty"`
+	CreatedBy     string `json:"createdBy"`
+	Instruction   string `json:"instruction,omitempty"` // RUN, COPY, ENV, ... empty if unknown
+	Command       string `json:"command,omitempty"`     // Arguments of the instruction
+	Author        string `json:"author,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+	EmptyLayer    bool   `json:"emptyLayer"`
+	LayerDigest   string `json:"layerDigest,omitempty"`
+	LayerSize     int64  `json:"layerSize"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status of the last run of a mirror
+const (
+	MirrorStatusSucceeded = "succeeded"
+	MirrorStatusPartial   = "partial" // Some tags failed
+	MirrorStatusFailed    = "failed"
+)
+
+// Mirror keeps the tags of an upstream repository that match a pattern copied
+// into a repository of the local registry
+type Mirror struct {
+	gorm.Model
+	Upstream   string        `json:"upstream"`                  // Upstream repository like "docker.io/library/postgres"
+	TagPattern string        `json:"tagPattern"`                // Glob the tags are filtered by like "16*", empty mirrors every tag
+	Target     string        `json:"target" gorm:"uniqueIndex"` // Local repository like "mirror/postgres"
+	Username   string        `json:"username,omitempty"`
+	Password   string        `json:"-"`
+	Cron       string        `json:"cron"` // When the mirror runs, or "manual" to only run on demand
+	NextRun    *time.Time    `json:"nextRun"`
+	LastRun    *time.Time    `json:"lastRun"`
+	LastStatus string        `json:"lastStatus,omitempty"`
+	LastError  string        `json:"lastError,omitempty" gorm:"type:text"`
+	Tags       []MirroredTag `json:"tags,omitempty"`
+}
+
+// MirroredTag records the digest a tag was last mirrored at, and why the last
+// attempt failed if it did
+type MirroredTag struct {
+	gorm.Model
+	MirrorID   uint       `json:"mirrorId" gorm:"uniqueIndex:idx_mirrored_tags_tag"`
+	Tag        string     `json:"tag" gorm:"uniqueIndex:idx_mirrored_tags_tag"`
+	Digest     string     `json:"digest,omitempty"`
+	MirroredAt *time.Time `json:"mirroredAt"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	FailedAt   *time.Time `json:"failedAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// LocalRegistryName refers to the registry the UI manages, which is
+// configured through the environment rather than stored
+const LocalRegistryName = "local"
+
+// Registry is another registry images can be copied from or to
+type Registry struct {
+	gorm.Model
+	Name     string `json:"name" gorm:"uniqueIndex"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetentionRule decides which tags of a namespace, or of a single image in
+// it, are deleted automatically. A tag is deleted when it matches all of the
+// delete conditions that are set and none of the keep conditions. A rule for
+// an image replaces the rule of its namespace.
+type RetentionRule struct {
+	gorm.Model
+	Namespace  string     `json:"namespace" gorm:"uniqueIndex:idx_retention_rules_scope"`
+	Image      string     `json:"image" gorm:"uniqueIndex:idx_retention_rules_scope"` // Empty for the whole namespace
+	KeepLatest int        `json:"keepLatest"`                                         // Number of newest tags always kept
+	OlderThan  string     `json:"olderThan"`                                          // Only delete tags whose image is older, like "30d"
+	TagPattern string     `json:"tagPattern"`                                         // Only delete tags matching this regular expression
+	KeepSemver bool       `json:"keepSemver"`                                         // Always keep semantic version releases like v1.2.3
+	Cron       string     `json:"cron"`                                               // When the rule runs, or "manual" to only run on demand
+	NextRun    *time.Time `json:"nextRun"`
+	LastRun    *time.Time `json:"lastRun"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// What started a sync run
+const (
+	SyncTriggerInterval = "interval"
+	SyncTriggerManual   = "manual"
+	SyncTriggerWebhook  = "webhook"
+	SyncTriggerRollback = "rollback"
+	SyncTriggerPromote  = "promote"
+	SyncTriggerCopy     = "copy"
+	SyncTriggerMirror   = "mirror"
+)
+
+// Status of a sync run
+const (
+	SyncRunStatusRunning   = "running"
+	SyncRunStatusSucceeded = "succeeded"
+	SyncRunStatusPartial   = "partial" // Finished, but some repositories or tags failed
+	SyncRunStatusFailed    = "failed"
+)
+
+// SyncRun records a single registry sync and its outcome
+type SyncRun struct {
+	gorm.Model
+	Trigger      string         `json:"trigger"`
+	Scope        string         `json:"scope,omitempty"` // Namespace, image or tag of a targeted sync, empty for the whole catalog
+	Status       string         `json:"status"`
+	StartedAt    time.Time      `json:"startedAt"`
+	FinishedAt   *time.Time     `json:"finishedAt"`
+	Repositories int            `json:"repositories"`
+	TagsCreated  int            `json:"tagsCreated"`
+	TagsUpdated  int            `json:"tagsUpdated"`
+	TagsDeleted  int            `json:"tagsDeleted"`
+	Error        string         `json:"error,omitempty"` // Error that aborted the whole run
+	Errors       []SyncRunError `json:"errors,omitempty" gorm:"foreignKey:SyncRunID"`
+}
+
+// SyncRunError is an error for a single repository or tag during a sync run
+type SyncRunError struct {
+	gorm.Model
+	SyncRunID  uint   `json:"syncRunId"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Message    string `json:"message" gorm:"type:text"`
+}
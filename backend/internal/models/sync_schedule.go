@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SyncScheduleManual disables automatic syncs for a schedule
+const SyncScheduleManual = "manual"
+
+// SyncSchedule is when the registry, or a single namespace, is synced
+// automatically. The schedule with an empty namespace covers the whole
+// catalog except namespaces that have their own schedule.
+type SyncSchedule struct {
+	gorm.Model
+	Namespace string     `json:"namespace" gorm:"uniqueIndex"`
+	Cron      string     `json:"cron"` // Cron expression, or "manual" to only sync on demand
+	NextRun   *time.Time `json:"nextRun"`
+	LastRun   *time.Time `json:"lastRun"`
+}
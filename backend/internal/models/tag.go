@@ -9,31 +9,84 @@ import (
 // Tag represents an image tag
 type Tag struct {
 	gorm.Model
-	ImageID   uint        `json:"imageId"`
-	Name      string      `json:"name"`
-	Digest    string      `json:"digest"`
-	CreatedAt time.Time   `json:"createdAt"`
-	Metadata  TagMetadata `json:"metadata,omitempty" gorm:"foreignKey:TagID"`
+	ImageID        uint          `json:"imageId"`
+	Name           string        `json:"name"`
+	Digest         string        `json:"digest"`
+	ManifestDigest string        `json:"manifestDigest"` // Digest the tag resolved to on the last sync, the index digest for multi-arch tags
+	CreatedAt      time.Time     `json:"createdAt"`
+	Metadata       TagMetadata   `json:"metadata,omitempty" gorm:"foreignKey:TagID"` // Metadata of the default platform
+	Platforms      []TagPlatform `json:"platforms,omitempty" gorm:"foreignKey:TagID"`
+	SyncVersion    int           `json:"-"` // Version of the sync that stored the tag
+}
+
+// TagPlatform is the image of a tag for one platform. Single-arch tags have
+// exactly one, multi-arch tags one per entry of their index.
+type TagPlatform struct {
+	gorm.Model
+	TagID          uint        `json:"tagId" gorm:"index"`
+	OS             string      `json:"os"`
+	Architecture   string      `json:"architecture"`
+	Variant        string      `json:"variant,omitempty"`
+	OSVersion      string      `json:"osVersion,omitempty"`
+	ManifestDigest string      `json:"manifestDigest"`
+	Size           int64       `json:"size"` // Compressed size of the layers
+	Metadata       TagMetadata `json:"metadata,omitempty" gorm:"foreignKey:TagPlatformID"`
 }
 
 // TagMetadata represents metadata for a tag
 type TagMetadata struct {
 	gorm.Model
-	TagID         uint         `json:"tagId"`
-	Created       string       `json:"created"`
-	OS            string       `json:"os"`
-	Architecture  string       `json:"architecture"`
-	Author        string       `json:"author"`
-	DockerFile    string       `json:"dockerFile" gorm:"type:text"`
-	ConfigDigest  string       `json:"configDigest"`
-	ExposedPorts  string       `json:"exposedPorts" gorm:"type:text"` // JSON string array
-	TotalSize     int64        `json:"totalSize"`
-	WorkDir       string       `json:"workDir"`
-	Command       string       `json:"command"`
-	Description   string       `json:"description"`
-	ContentDigest string       `json:"contentDigest"`
-	Entrypoint    string       `json:"entrypoint"`
-	IndexDigest   string       `json:"indexDigest"`
-	IsOCI         bool         `json:"isOCI"`
-	Layers        []ImageLayer `json:"layers,omitempty" gorm:"foreignKey:TagMetadataID"`
+	TagID         uint           `json:"tagId"`
+	TagPlatformID uint           `json:"tagPlatformId,omitempty" gorm:"index"` // Set instead of TagID for the metadata of a platform
+	Created       string         `json:"created"`
+	OS            string         `json:"os"`
+	Architecture  string         `json:"architecture"`
+	Author        string         `json:"author"`
+	DockerFile    string         `json:"dockerFile" gorm:"type:text"`
+	ConfigDigest  string         `json:"configDigest"`
+	ExposedPorts  string         `json:"exposedPorts" gorm:"type:text"` // JSON string array
+	TotalSize     int64          `json:"totalSize"`
+	WorkDir       string         `json:"workDir"`
+	Command       string         `json:"command"`
+	Description   string         `json:"description"`
+	ContentDigest string         `json:"contentDigest"`
+	Entrypoint    string         `json:"entrypoint"`
+	IndexDigest   string         `json:"indexDigest"`
+	IsOCI         bool           `json:"isOCI"`
+	Layers        []ImageLayer   `json:"layers,omitempty" gorm:"foreignKey:TagMetadataID"`
+	History       []HistoryEntry `json:"history,omitempty" gorm:"foreignKey:TagMetadataID"`
+
+	// Runtime configuration from the image config
+	Env         []string          `json:"env,omitempty" gorm:"serializer:json"`
+	User        string            `json:"user,omitempty"`
+	Volumes     []string          `json:"volumes,omitempty" gorm:"serializer:json"`
+	Healthcheck *Healthcheck      `json:"healthcheck,omitempty" gorm:"serializer:json"`
+	StopSignal  string            `json:"stopSignal,omitempty"`
+	Shell       []string          `json:"shell,omitempty" gorm:"serializer:json"`
+	OnBuild     []string          `json:"onBuild,omitempty" gorm:"serializer:json"`
+	Labels      map[string]string `json:"labels,omitempty" gorm:"serializer:json"`
+}
+
+// Healthcheck is the health check an image runs its containers with.
+// Durations are in nanoseconds like in the image config.
+type Healthcheck struct {
+	Test          []string      `json:"test,omitempty"`
+	Interval      time.Duration `json:"interval,omitempty"`
+	Timeout       time.Duration `json:"timeout,omitempty"`
+	StartPeriod   time.Duration `json:"startPeriod,omitempty"`
+	StartInterval time.Duration `json:"startInterval,omitempty"`
+	Retries       int           `json:"retries,omitempty"`
+}
+
+// TagRevision is a manifest a tag pointed to, from when the sync first saw it
+// until it was replaced or the tag was removed. The current one has no ReplacedAt.
+type TagRevision struct {
+	gorm.Model
+	ImageID    uint       `json:"imageId" gorm:"index:idx_tag_revisions_tag"`
+	TagName    string     `json:"tagName" gorm:"index:idx_tag_revisions_tag"`
+	Digest     string     `json:"digest"`
+	MediaType  string     `json:"mediaType"`
+	Manifest   []byte     `json:"-"` // Empty if the registry no longer had it when the revision was recorded
+	FirstSeen  time.Time  `json:"firstSeen"`
+	ReplacedAt *time.Time `json:"replacedAt,omitempty"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TagProtection protects the tags of a namespace whose name matches a
+// pattern. Protected tags can't be deleted, and the sync records a violation
+// when one points to a different manifest than before.
+type TagProtection struct {
+	gorm.Model
+	Namespace string `json:"namespace" gorm:"uniqueIndex:idx_tag_protections_pattern"`
+	Pattern   string `json:"pattern" gorm:"uniqueIndex:idx_tag_protections_pattern"` // Regular expression the whole tag name must match
+}
+
+// ProtectionViolation is a protected tag the sync found pointing to a
+// different manifest, meaning it was overwritten in the registry
+type ProtectionViolation struct {
+	gorm.Model
+	Namespace      string    `json:"namespace" gorm:"index"`
+	Image          string    `json:"image"`
+	Tag            string    `json:"tag"`
+	Pattern        string    `json:"pattern"` // Pattern that protects the tag
+	PreviousDigest string    `json:"previousDigest"`
+	Digest         string    `json:"digest"`
+	DetectedAt     time.Time `json:"detectedAt"`
+}
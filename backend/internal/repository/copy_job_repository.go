@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// CopyJobRepository handles database operations for copy jobs
+type CopyJobRepository interface {
+	ListJobs(ctx context.Context, page, limit int) ([]models.CopyJob, int64, error)
+	// GetJob returns a job, nil if it doesn't exist
+	GetJob(ctx context.Context, id uint) (*models.CopyJob, error)
+	CreateJob(ctx context.Context, job *models.CopyJob) error
+	UpdateJob(ctx context.Context, job *models.CopyJob) error
+	// FailUnfinishedJobs marks queued and running jobs as failed, for jobs a
+	// restart interrupted
+	FailUnfinishedJobs(ctx context.Context, message string) (int64, error)
+}
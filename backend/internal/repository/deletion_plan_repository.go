@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// DeletionPlanRepository handles database operations for deletion plans
+type DeletionPlanRepository interface {
+	ListPlans(ctx context.Context, filter DeletionPlanFilter, page, limit int) ([]models.DeletionPlan, int64, error)
+	// GetPlan returns a plan with its items, nil if it doesn't exist
+	GetPlan(ctx context.Context, id uint) (*models.DeletionPlan, error)
+	CreatePlan(ctx context.Context, plan *models.DeletionPlan) error
+	// UpdatePlan saves a plan together with its items
+	UpdatePlan(ctx context.Context, plan *models.DeletionPlan) error
+}
+
+// DeletionPlanFilter narrows a list of deletion plans, the zero value matches all
+type DeletionPlanFilter struct {
+	Source string
+	RuleID uint
+}
@@ -13,5 +13,6 @@ type DockerRepository interface {
 	GetRepository(ctx context.Context, name string) (*models.Repository, error)
 	CreateRepository(ctx context.Context, repo *models.Repository) error
 	UpdateRepository(ctx context.Context, repo *models.Repository) error
-	DeleteRepository(ctx context.Context, name string) error
+	// DeleteEmptyRepositories removes namespaces without images and returns their names
+	DeleteEmptyRepositories(ctx context.Context) ([]string, error)
 }
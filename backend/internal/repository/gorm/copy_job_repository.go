@@ -0,0 +1,64 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type copyJobRepository struct {
+	db *gorm.DB
+}
+
+func NewCopyJobRepository(db *gorm.DB) repository.CopyJobRepository {
+	return &copyJobRepository{db: db}
+}
+
+func (r *copyJobRepository) ListJobs(ctx context.Context, page, limit int) ([]models.CopyJob, int64, error) {
+	var jobs []models.CopyJob
+	var total int64
+
+	if err := r.db.Model(&models.CopyJob{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := r.db.Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+
+	return jobs, total, err
+}
+
+func (r *copyJobRepository) GetJob(ctx context.Context, id uint) (*models.CopyJob, error) {
+	var job models.CopyJob
+	err := r.db.First(&job, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *copyJobRepository) CreateJob(ctx context.Context, job *models.CopyJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *copyJobRepository) UpdateJob(ctx context.Context, job *models.CopyJob) error {
+	return r.db.Save(job).Error
+}
+
+func (r *copyJobRepository) FailUnfinishedJobs(ctx context.Context, message string) (int64, error) {
+	result := r.db.Model(&models.CopyJob{}).
+		Where("status IN ?", []string{models.CopyJobStatusQueued, models.CopyJobStatusRunning}).
+		Updates(map[string]any{
+			"status":      models.CopyJobStatusFailed,
+			"error":       message,
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
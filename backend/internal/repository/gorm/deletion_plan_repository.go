@@ -0,0 +1,77 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type deletionPlanRepository struct {
+	db *gorm.DB
+}
+
+func NewDeletionPlanRepository(db *gorm.DB) repository.DeletionPlanRepository {
+	return &deletionPlanRepository{db: db}
+}
+
+func (r *deletionPlanRepository) ListPlans(ctx context.Context, filter repository.DeletionPlanFilter, page, limit int) ([]models.DeletionPlan, int64, error) {
+	var plans []models.DeletionPlan
+	var total int64
+
+	query := r.db.Model(&models.DeletionPlan{})
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.RuleID != 0 {
+		query = query.Where("rule_id = ?", filter.RuleID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&plans).Error
+
+	return plans, total, err
+}
+
+func (r *deletionPlanRepository) GetPlan(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	var plan models.DeletionPlan
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&plan, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *deletionPlanRepository) CreatePlan(ctx context.Context, plan *models.DeletionPlan) error {
+	return r.db.Create(plan).Error
+}
+
+func (r *deletionPlanRepository) UpdatePlan(ctx context.Context, plan *models.DeletionPlan) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(plan).Error; err != nil {
+			return fmt.Errorf("failed to save deletion plan: %w", err)
+		}
+
+		for i := range plan.Items {
+			plan.Items[i].DeletionPlanID = plan.ID
+		}
+		if len(plan.Items) > 0 {
+			if err := tx.Save(&plan.Items).Error; err != nil {
+				return fmt.Errorf("failed to save deletion plan items: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
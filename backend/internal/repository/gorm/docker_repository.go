@@ -7,6 +7,7 @@ import (
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type dockerRepository struct {
@@ -62,9 +63,32 @@ func (r *dockerRepository) CreateRepository(ctx context.Context, repo *models.Re
 }
 
 func (r *dockerRepository) UpdateRepository(ctx context.Context, repo *models.Repository) error {
-	return r.db.Save(repo).Error
+	// Don't write back preloaded images and tags, they may be stale by now
+	return r.db.Omit(clause.Associations).Save(repo).Error
 }
 
-func (r *dockerRepository) DeleteRepository(ctx context.Context, name string) error {
-	return r.db.Where("name = ?", name).Delete(&models.Repository{}).Error
+func (r *dockerRepository) DeleteEmptyRepositories(ctx context.Context) ([]string, error) {
+	var repositories []models.Repository
+	err := r.db.Where("NOT EXISTS (SELECT 1 FROM images WHERE images.repository_id = repositories.id)").
+		Find(&repositories).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(repositories) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, len(repositories))
+	ids := make([]uint, len(repositories))
+	for i, repo := range repositories {
+		names[i] = repo.Name
+		ids[i] = repo.ID
+	}
+
+	// Hard delete so the unique name can be used again if the namespace comes back
+	if err := r.db.Unscoped().Where("id IN ?", ids).Delete(&models.Repository{}).Error; err != nil {
+		return nil, err
+	}
+
+	return names, nil
 }
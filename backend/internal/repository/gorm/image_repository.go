@@ -3,10 +3,13 @@ package gorm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type imageRepository struct {
@@ -41,16 +44,116 @@ func (r *imageRepository) GetImage(ctx context.Context, repoName, imageName stri
 	return &image, nil
 }
 
+func (r *imageRepository) GetImageByPath(ctx context.Context, fullName string) (*models.Image, error) {
+	var image models.Image
+	err := r.db.Where("full_name = ?", fullName).First(&image).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
 func (r *imageRepository) CreateImage(ctx context.Context, image *models.Image) error {
 	return r.db.Create(image).Error
 }
 
 func (r *imageRepository) UpdateImage(ctx context.Context, image *models.Image) error {
-	return r.db.Save(image).Error
+	// Don't write back preloaded tags, they may be stale by now
+	return r.db.Omit(clause.Associations).Save(image).Error
 }
 
-func (r *imageRepository) DeleteImage(ctx context.Context, repoName, imageName string) error {
-	return r.db.Joins("JOIN repositories ON repositories.id = images.repository_id").
-		Where("repositories.name = ? AND images.name = ?", repoName, imageName).
-		Delete(&models.Image{}).Error
+func (r *imageRepository) SetLastSynced(ctx context.Context, imageID uint, syncedAt time.Time) error {
+	// Only move forward, a targeted run that started before a full run may
+	// finish after it and would otherwise get the image removed as stale
+	return r.db.Model(&models.Image{}).
+		Where("id = ? AND (last_synced IS NULL OR last_synced < ?)", imageID, syncedAt).
+		Update("last_synced", syncedAt).Error
+}
+
+func (r *imageRepository) DeleteStaleImages(ctx context.Context, before time.Time, scope repository.ImageScope) ([]models.Image, error) {
+	var images []models.Image
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := withImageScope(tx, scope).Where("last_synced IS NULL OR last_synced < ?", before)
+		if err := query.Find(&images).Error; err != nil {
+			return fmt.Errorf("failed to find stale images: %w", err)
+		}
+		if len(images) == 0 {
+			return nil
+		}
+
+		imageIDs := make([]uint, len(images))
+		for i, image := range images {
+			imageIDs[i] = image.ID
+		}
+		return deleteImagesCascade(tx, imageIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (r *imageRepository) IncrementPullCount(ctx context.Context, repoName, imageName string, n int) error {
+	repoIDs := r.db.Model(&models.Repository{}).Select("id").Where("name = ?", repoName)
+	return r.db.Model(&models.Image{}).
+		Where("name = ? AND repository_id IN (?)", imageName, repoIDs).
+		UpdateColumn("pull_count", gorm.Expr("pull_count + ?", n)).Error
+}
+
+func (r *imageRepository) FindImages(ctx context.Context, scope repository.ImageScope) ([]models.Image, error) {
+	var images []models.Image
+	err := withImageScope(r.db, scope).
+		Preload("Tags.Metadata").
+		Preload("Tags.Platforms").
+		Order("full_name ASC").
+		Find(&images).Error
+	return images, err
+}
+
+// withImageScope narrows a query on images to scope
+func withImageScope(db *gorm.DB, scope repository.ImageScope) *gorm.DB {
+	if scope.Namespace != "" {
+		db = db.Where("repository_id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.Repository{}).Select("id").Where("name = ?", scope.Namespace))
+		if scope.Image != "" {
+			db = db.Where("name = ?", scope.Image)
+		}
+	}
+	if len(scope.ExcludeNamespaces) > 0 {
+		db = db.Where("repository_id NOT IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.Repository{}).Select("id").Where("name IN ?", scope.ExcludeNamespaces))
+	}
+	return db
+}
+
+func (r *imageRepository) RemoveImage(ctx context.Context, imageID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return deleteImagesCascade(tx, []uint{imageID})
+	})
+}
+
+// deleteImagesCascade permanently removes images together with their tags and tag revisions
+func deleteImagesCascade(tx *gorm.DB, imageIDs []uint) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	var tagIDs []uint
+	if err := tx.Unscoped().Model(&models.Tag{}).Where("image_id IN ?", imageIDs).Pluck("id", &tagIDs).Error; err != nil {
+		return fmt.Errorf("failed to find tags of images: %w", err)
+	}
+	if err := deleteTagsCascade(tx, tagIDs); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("image_id IN ?", imageIDs).Delete(&models.TagRevision{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tag revisions: %w", err)
+	}
+
+	if err := tx.Unscoped().Where("id IN ?", imageIDs).Delete(&models.Image{}).Error; err != nil {
+		return fmt.Errorf("failed to delete images: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,90 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type mirrorRepository struct {
+	db *gorm.DB
+}
+
+func NewMirrorRepository(db *gorm.DB) repository.MirrorRepository {
+	return &mirrorRepository{db: db}
+}
+
+func (r *mirrorRepository) ListMirrors(ctx context.Context) ([]models.Mirror, error) {
+	var mirrors []models.Mirror
+	err := r.db.Order("target").Find(&mirrors).Error
+	return mirrors, err
+}
+
+func (r *mirrorRepository) GetMirror(ctx context.Context, id uint) (*models.Mirror, error) {
+	var mirror models.Mirror
+	err := r.db.Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tag ASC")
+	}).First(&mirror, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mirror, nil
+}
+
+func (r *mirrorRepository) FindMirror(ctx context.Context, target string) (*models.Mirror, error) {
+	var mirror models.Mirror
+	err := r.db.Where("target = ?", target).First(&mirror).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mirror, nil
+}
+
+func (r *mirrorRepository) SaveMirror(ctx context.Context, mirror *models.Mirror) error {
+	if mirror.ID == 0 {
+		return r.db.Omit("Tags").Create(mirror).Error
+	}
+	// Only touch the settings, the scheduler updates the run state concurrently
+	return r.db.Model(mirror).
+		Select("upstream", "tag_pattern", "target", "username", "password", "cron", "next_run").
+		Updates(mirror).Error
+}
+
+func (r *mirrorRepository) DeleteMirror(ctx context.Context, id uint) error {
+	// Hard delete so the target can get a new mirror
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("mirror_id = ?", id).Delete(&models.MirroredTag{}).Error; err != nil {
+			return fmt.Errorf("failed to delete mirrored tags: %w", err)
+		}
+		return tx.Unscoped().Delete(&models.Mirror{}, id).Error
+	})
+}
+
+func (r *mirrorRepository) UpdateMirrorState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error {
+	return r.db.Model(&models.Mirror{}).Where("id = ?", id).Updates(map[string]any{
+		"last_run": lastRun,
+		"next_run": nextRun,
+	}).Error
+}
+
+func (r *mirrorRepository) UpdateMirrorResult(ctx context.Context, id uint, status, message string) error {
+	return r.db.Model(&models.Mirror{}).Where("id = ?", id).Updates(map[string]any{
+		"last_status": status,
+		"last_error":  message,
+	}).Error
+}
+
+func (r *mirrorRepository) SaveMirroredTag(ctx context.Context, tag *models.MirroredTag) error {
+	return r.db.Save(tag).Error
+}
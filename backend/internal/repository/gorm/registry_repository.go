@@ -0,0 +1,45 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type registryRepository struct {
+	db *gorm.DB
+}
+
+func NewRegistryRepository(db *gorm.DB) repository.RegistryRepository {
+	return &registryRepository{db: db}
+}
+
+func (r *registryRepository) ListRegistries(ctx context.Context) ([]models.Registry, error) {
+	var registries []models.Registry
+	err := r.db.Order("name").Find(&registries).Error
+	return registries, err
+}
+
+func (r *registryRepository) GetRegistry(ctx context.Context, name string) (*models.Registry, error) {
+	var registry models.Registry
+	err := r.db.Where("name = ?", name).First(&registry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &registry, nil
+}
+
+func (r *registryRepository) SaveRegistry(ctx context.Context, registry *models.Registry) error {
+	return r.db.Save(registry).Error
+}
+
+func (r *registryRepository) DeleteRegistry(ctx context.Context, name string) error {
+	// Hard delete so the name can be used again
+	return r.db.Unscoped().Where("name = ?", name).Delete(&models.Registry{}).Error
+}
@@ -0,0 +1,71 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type retentionRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewRetentionRuleRepository(db *gorm.DB) repository.RetentionRuleRepository {
+	return &retentionRuleRepository{db: db}
+}
+
+func (r *retentionRuleRepository) ListRules(ctx context.Context) ([]models.RetentionRule, error) {
+	var rules []models.RetentionRule
+	err := r.db.Order("namespace, image").Find(&rules).Error
+	return rules, err
+}
+
+func (r *retentionRuleRepository) GetRule(ctx context.Context, id uint) (*models.RetentionRule, error) {
+	var rule models.RetentionRule
+	err := r.db.First(&rule, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *retentionRuleRepository) FindRule(ctx context.Context, namespace, image string) (*models.RetentionRule, error) {
+	var rule models.RetentionRule
+	err := r.db.Where("namespace = ? AND image = ?", namespace, image).First(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *retentionRuleRepository) SaveRule(ctx context.Context, rule *models.RetentionRule) error {
+	if rule.ID == 0 {
+		return r.db.Create(rule).Error
+	}
+	// Only touch the settings, the scheduler updates the run state concurrently
+	return r.db.Model(rule).
+		Select("namespace", "image", "keep_latest", "older_than", "tag_pattern", "keep_semver", "cron", "next_run").
+		Updates(rule).Error
+}
+
+func (r *retentionRuleRepository) DeleteRule(ctx context.Context, id uint) error {
+	// Hard delete so the namespace or image can get a new rule
+	return r.db.Unscoped().Delete(&models.RetentionRule{}, id).Error
+}
+
+func (r *retentionRuleRepository) UpdateRuleState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error {
+	return r.db.Model(&models.RetentionRule{}).Where("id = ?", id).Updates(map[string]any{
+		"last_run": lastRun,
+		"next_run": nextRun,
+	}).Error
+}
@@ -0,0 +1,97 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type syncRunRepository struct {
+	db *gorm.DB
+}
+
+func NewSyncRunRepository(db *gorm.DB) repository.SyncRunRepository {
+	return &syncRunRepository{db: db}
+}
+
+func (r *syncRunRepository) ListRuns(ctx context.Context, page, limit int) ([]models.SyncRun, int64, error) {
+	var runs []models.SyncRun
+	var total int64
+
+	query := r.db.Model(&models.SyncRun{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&runs).Error
+
+	return runs, total, err
+}
+
+func (r *syncRunRepository) GetRun(ctx context.Context, id uint) (*models.SyncRun, error) {
+	var run models.SyncRun
+	err := r.db.Preload("Errors").First(&run, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *syncRunRepository) CreateRun(ctx context.Context, run *models.SyncRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *syncRunRepository) UpdateRun(ctx context.Context, run *models.SyncRun) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Errors").Save(run).Error; err != nil {
+			return fmt.Errorf("failed to save sync run: %w", err)
+		}
+
+		for i := range run.Errors {
+			run.Errors[i].SyncRunID = run.ID
+		}
+		if len(run.Errors) > 0 {
+			if err := tx.Save(&run.Errors).Error; err != nil {
+				return fmt.Errorf("failed to save sync run errors: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *syncRunRepository) PruneRuns(ctx context.Context, keep int, targeted bool) error {
+	// Targeted syncs are the ones with a scope
+	kind := "scope = ''"
+	if targeted {
+		kind = "scope <> ''"
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var cutoff models.SyncRun
+		err := tx.Unscoped().Where(kind).Order("id DESC").Offset(keep).Limit(1).Find(&cutoff).Error
+		if err != nil {
+			return err
+		}
+		if cutoff.ID == 0 {
+			return nil
+		}
+
+		pruned := tx.Unscoped().Model(&models.SyncRun{}).Select("id").Where(kind).Where("id <= ?", cutoff.ID)
+		if err := tx.Unscoped().Where("sync_run_id IN (?)", pruned).Delete(&models.SyncRunError{}).Error; err != nil {
+			return fmt.Errorf("failed to prune sync run errors: %w", err)
+		}
+		if err := tx.Unscoped().Where(kind).Where("id <= ?", cutoff.ID).Delete(&models.SyncRun{}).Error; err != nil {
+			return fmt.Errorf("failed to prune sync runs: %w", err)
+		}
+		return nil
+	})
+}
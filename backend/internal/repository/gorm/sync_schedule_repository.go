@@ -0,0 +1,56 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type syncScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewSyncScheduleRepository(db *gorm.DB) repository.SyncScheduleRepository {
+	return &syncScheduleRepository{db: db}
+}
+
+func (r *syncScheduleRepository) ListSchedules(ctx context.Context) ([]models.SyncSchedule, error) {
+	var schedules []models.SyncSchedule
+	err := r.db.Order("namespace").Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *syncScheduleRepository) GetSchedule(ctx context.Context, namespace string) (*models.SyncSchedule, error) {
+	var schedule models.SyncSchedule
+	err := r.db.Where("namespace = ?", namespace).First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *syncScheduleRepository) SaveSchedule(ctx context.Context, schedule *models.SyncSchedule) error {
+	if schedule.ID == 0 {
+		return r.db.Create(schedule).Error
+	}
+	// Only touch the settings, the scheduler updates the run state concurrently
+	return r.db.Model(schedule).Select("cron", "next_run").Updates(schedule).Error
+}
+
+func (r *syncScheduleRepository) DeleteSchedule(ctx context.Context, namespace string) error {
+	return r.db.Unscoped().Where("namespace = ?", namespace).Delete(&models.SyncSchedule{}).Error
+}
+
+func (r *syncScheduleRepository) UpdateScheduleState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error {
+	return r.db.Model(&models.SyncSchedule{}).Where("id = ?", id).Updates(map[string]any{
+		"last_run": lastRun,
+		"next_run": nextRun,
+	}).Error
+}
@@ -0,0 +1,83 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+type tagProtectionRepository struct {
+	db *gorm.DB
+}
+
+func NewTagProtectionRepository(db *gorm.DB) repository.TagProtectionRepository {
+	return &tagProtectionRepository{db: db}
+}
+
+func (r *tagProtectionRepository) ListProtections(ctx context.Context, namespace string) ([]models.TagProtection, error) {
+	var protections []models.TagProtection
+	query := r.db.Order("namespace, pattern")
+	if namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+	err := query.Find(&protections).Error
+	return protections, err
+}
+
+func (r *tagProtectionRepository) GetProtection(ctx context.Context, id uint) (*models.TagProtection, error) {
+	var protection models.TagProtection
+	err := r.db.First(&protection, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &protection, nil
+}
+
+func (r *tagProtectionRepository) FindProtection(ctx context.Context, namespace, pattern string) (*models.TagProtection, error) {
+	var protection models.TagProtection
+	err := r.db.Where("namespace = ? AND pattern = ?", namespace, pattern).First(&protection).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &protection, nil
+}
+
+func (r *tagProtectionRepository) CreateProtection(ctx context.Context, protection *models.TagProtection) error {
+	return r.db.Create(protection).Error
+}
+
+func (r *tagProtectionRepository) DeleteProtection(ctx context.Context, id uint) error {
+	// Hard delete so the pattern can be added again
+	return r.db.Unscoped().Delete(&models.TagProtection{}, id).Error
+}
+
+func (r *tagProtectionRepository) ListViolations(ctx context.Context, namespace string, page, limit int) ([]models.ProtectionViolation, int64, error) {
+	var violations []models.ProtectionViolation
+	var total int64
+
+	query := r.db.Model(&models.ProtectionViolation{})
+	if namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&violations).Error
+
+	return violations, total, err
+}
+
+func (r *tagProtectionRepository) RecordViolation(ctx context.Context, violation *models.ProtectionViolation) error {
+	return r.db.Create(violation).Error
+}
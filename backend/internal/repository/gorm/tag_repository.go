@@ -4,18 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
 	"github.com/ofkm/svelocker-ui/backend/internal/repository"
-	"github.com/ofkm/svelocker-ui/backend/internal/services"
-	"github.com/ofkm/svelocker-ui/backend/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type tagRepository struct {
@@ -31,6 +25,7 @@ func (r *tagRepository) ListTags(ctx context.Context, repoName, imageName string
 	err := r.db.Joins("JOIN images ON images.id = tags.image_id").
 		Joins("JOIN repositories ON repositories.id = images.repository_id").
 		Where("repositories.name = ? AND images.name = ?", repoName, imageName).
+		Preload("Platforms").
 		Find(&tags).Error
 	return tags, err
 }
@@ -41,6 +36,7 @@ func (r *tagRepository) GetTag(ctx context.Context, repoName, imageName, tagName
 		Joins("JOIN repositories ON repositories.id = images.repository_id").
 		Where("repositories.name = ? AND images.name = ? AND tags.name = ?", repoName, imageName, tagName).
 		Preload("Metadata.Layers").
+		Preload("Platforms.Metadata.Layers").
 		First(&tag).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -53,208 +49,263 @@ func (r *tagRepository) GetTag(ctx context.Context, repoName, imageName, tagName
 
 func (r *tagRepository) CreateTag(ctx context.Context, tag *models.Tag) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Create the tag first
-		if err := tx.Create(tag).Error; err != nil {
+		// Associations are created by createTagContents, gorm would
+		// otherwise create them a second time
+		if err := tx.Omit(clause.Associations).Create(tag).Error; err != nil {
 			return fmt.Errorf("failed to create tag: %w", err)
 		}
 
-		// Set the TagID for the metadata and ensure ID is not set
-		tag.Metadata.TagID = tag.ID
-		tag.Metadata.ID = 0 // Reset ID to ensure auto-increment
-
-		// Create the metadata
-		if err := tx.Create(&tag.Metadata).Error; err != nil {
-			return fmt.Errorf("failed to create tag metadata: %w", err)
-		}
-
-		// Create the layers if they exist
-		if len(tag.Metadata.Layers) > 0 {
-			for i := range tag.Metadata.Layers {
-				tag.Metadata.Layers[i].TagMetadataID = tag.Metadata.ID
-				tag.Metadata.Layers[i].ID = 0 // Reset ID to ensure auto-increment
-			}
-			if err := tx.Create(&tag.Metadata.Layers).Error; err != nil {
-				return fmt.Errorf("failed to create layers: %w", err)
-			}
-		}
-
-		return nil
+		return createTagContents(tx, tag)
 	})
 }
 
+// UpdateTag saves a tag and replaces its metadata, layers and platforms
 func (r *tagRepository) UpdateTag(ctx context.Context, tag *models.Tag) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Update the tag itself
-		if err := tx.Save(tag).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Save(tag).Error; err != nil {
 			return fmt.Errorf("failed to save tag: %w", err)
 		}
 
-		// Update or create metadata
-		if tag.Metadata.ID != 0 {
-			if err := tx.Save(&tag.Metadata).Error; err != nil {
-				return fmt.Errorf("failed to update metadata: %w", err)
-			}
-		} else {
-			tag.Metadata.TagID = tag.ID
-			if err := tx.Create(&tag.Metadata).Error; err != nil {
-				return fmt.Errorf("failed to create metadata: %w", err)
-			}
+		if err := deleteTagContents(tx, []uint{tag.ID}); err != nil {
+			return err
 		}
 
-		// Handle layers
-		if len(tag.Metadata.Layers) > 0 {
-			// Delete existing layers
-			if err := tx.Where("tag_metadata_id = ?", tag.Metadata.ID).Delete(&models.ImageLayer{}).Error; err != nil {
-				return fmt.Errorf("failed to delete existing layers: %w", err)
-			}
-
-			// Reset layer IDs and set metadata ID
-			for i := range tag.Metadata.Layers {
-				tag.Metadata.Layers[i].ID = 0 // Reset ID to ensure auto-increment
-				tag.Metadata.Layers[i].TagMetadataID = tag.Metadata.ID
-			}
-
-			// Create new layers
-			if err := tx.Create(&tag.Metadata.Layers).Error; err != nil {
-				return fmt.Errorf("failed to create new layers: %w", err)
-			}
-		}
-
-		return nil
+		return createTagContents(tx, tag)
 	})
 }
 
-func (r *tagRepository) DeleteTag(ctx context.Context, repoName, imageName, tagName string) error {
-	// Start a transaction
-	tx := r.db.Begin()
-
-	// Find the tag to delete
-	var tag models.Tag
+// createTagContents creates the metadata of a tag and its platforms, each
+// with their layers and history
+func createTagContents(tx *gorm.DB, tag *models.Tag) error {
+	tag.Metadata.TagID = tag.ID
+	tag.Metadata.TagPlatformID = 0
+	if err := createMetadata(tx, &tag.Metadata); err != nil {
+		return err
+	}
 
-	// Find the tag using the correct JOIN structure
-	result := tx.Joins("JOIN images ON images.id = tags.image_id").
-		Joins("JOIN repositories ON repositories.id = images.repository_id").
-		Where("repositories.name = ? AND images.name = ? AND tags.name = ?", repoName, imageName, tagName).
-		Preload("Metadata").
-		First(&tag)
+	for i := range tag.Platforms {
+		platform := &tag.Platforms[i]
+		platform.ID = 0 // Reset ID to ensure auto-increment
+		platform.TagID = tag.ID
+		if err := tx.Omit(clause.Associations).Create(platform).Error; err != nil {
+			return fmt.Errorf("failed to create tag platform: %w", err)
+		}
 
-	if result.Error != nil {
-		tx.Rollback()
-		return result.Error
+		platform.Metadata.TagID = 0
+		platform.Metadata.TagPlatformID = platform.ID
+		if err := createMetadata(tx, &platform.Metadata); err != nil {
+			return err
+		}
 	}
 
-	// Store various digests we might use for deletion
-	var possibleDigests []string
+	return nil
+}
 
-	// Add the tag's primary digest
-	if tag.Digest != "" {
-		possibleDigests = append(possibleDigests, tag.Digest)
+func createMetadata(tx *gorm.DB, metadata *models.TagMetadata) error {
+	metadata.ID = 0 // Reset ID to ensure auto-increment
+	if err := tx.Omit(clause.Associations).Create(metadata).Error; err != nil {
+		return fmt.Errorf("failed to create tag metadata: %w", err)
 	}
 
-	// Add metadata digests if available
-	if tag.Metadata.IndexDigest != "" {
-		possibleDigests = append(possibleDigests, tag.Metadata.IndexDigest)
-	}
-	if tag.Metadata.ContentDigest != "" {
-		possibleDigests = append(possibleDigests, tag.Metadata.ContentDigest)
+	if len(metadata.Layers) > 0 {
+		for i := range metadata.Layers {
+			metadata.Layers[i].ID = 0
+			metadata.Layers[i].TagMetadataID = metadata.ID
+		}
+		if err := tx.Create(&metadata.Layers).Error; err != nil {
+			return fmt.Errorf("failed to create layers: %w", err)
+		}
 	}
 
-	// Delete metadata and tag from database first
-	if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.TagMetadata{}).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err := tx.Delete(&tag).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-	if err := tx.Commit().Error; err != nil {
-		return err
+	if len(metadata.History) > 0 {
+		for i := range metadata.History {
+			metadata.History[i].ID = 0
+			metadata.History[i].TagMetadataID = metadata.ID
+		}
+		if err := tx.Create(&metadata.History).Error; err != nil {
+			return fmt.Errorf("failed to create history: %w", err)
+		}
 	}
 
-	sanitizedTagName := strings.ReplaceAll(tagName, "\n", "")
-	sanitizedTagName = strings.ReplaceAll(sanitizedTagName, "\r", "")
-	log.Printf("Successfully deleted tag %s from database", sanitizedTagName)
+	return nil
+}
 
-	// Registry path setup
-	baseURL := os.Getenv("PUBLIC_REGISTRY_URL")
-	username := os.Getenv("REGISTRY_USERNAME")
-	password := os.Getenv("REGISTRY_PASSWORD")
-	client := services.NewRegistryClient(baseURL, username, password)
+func (r *tagRepository) RemoveTag(ctx context.Context, tagID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return deleteTagsCascade(tx, []uint{tagID})
+	})
+}
 
-	registryPath := imageName
-	if repoName != "library" {
-		registryPath = fmt.Sprintf("%s/%s", repoName, imageName)
+func (r *tagRepository) ExclusiveLayerSize(ctx context.Context, tagIDs []uint) (int64, error) {
+	if len(tagIDs) == 0 {
+		return 0, nil
 	}
 
-	// First try to get the current manifest to extract its digest
-	// This is the most reliable approach for getting the correct digest
-	currentManifestDigest := getManifestDigestForTag(ctx, baseURL, username, password, registryPath, tagName)
-	if currentManifestDigest != "" {
-		possibleDigests = append([]string{currentManifestDigest}, possibleDigests...)
-	}
+	metadataIDs := r.db.Model(&models.TagMetadata{}).Select("id").
+		Where("tag_id IN ? OR tag_platform_id IN (?)", tagIDs, r.db.Model(&models.TagPlatform{}).Select("id").Where("tag_id IN ?", tagIDs))
+	usedElsewhere := r.db.Model(&models.ImageLayer{}).Select("digest").
+		Where("tag_metadata_id NOT IN (?)", metadataIDs)
+	layers := r.db.Model(&models.ImageLayer{}).Select("digest, MAX(size) AS size").
+		Where("tag_metadata_id IN (?) AND digest NOT IN (?)", metadataIDs, usedElsewhere).
+		Group("digest")
+
+	var size int64
+	err := r.db.Table("(?) AS layers", layers).Select("COALESCE(SUM(size), 0)").Scan(&size).Error
+	return size, err
+}
 
-	// Try deleting using each digest until one succeeds
-	var lastErr error
-	for _, digest := range possibleDigests {
-		log.Printf("Attempting to delete manifest using digest: %s", digest)
-
-		if err := client.DeleteManifest(ctx, registryPath, digest); err == nil {
-			log.Printf("Successfully deleted manifest with digest: %s", digest)
-			return nil
-		} else {
-			log.Printf("Failed to delete manifest with digest %s: %v", digest, err)
-			lastErr = err
+func (r *tagRepository) ListHistory(ctx context.Context, metadataID uint) ([]models.HistoryEntry, error) {
+	var history []models.HistoryEntry
+	err := r.db.Where("tag_metadata_id = ?", metadataID).
+		Order("step ASC").
+		Find(&history).Error
+	return history, err
+}
+
+func (r *tagRepository) RecordRevision(ctx context.Context, revision *models.TagRevision) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.TagRevision
+		err := tx.Where("image_id = ? AND tag_name = ? AND replaced_at IS NULL", revision.ImageID, revision.TagName).
+			Order("first_seen DESC").
+			First(&current).Error
+		switch {
+		case err == nil:
+			if current.Digest == revision.Digest {
+				return nil
+			}
+			if err := tx.Model(&current).Update("replaced_at", revision.FirstSeen).Error; err != nil {
+				return fmt.Errorf("failed to replace tag revision: %w", err)
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return fmt.Errorf("failed to get current tag revision: %w", err)
+		}
+
+		if err := tx.Create(revision).Error; err != nil {
+			return fmt.Errorf("failed to create tag revision: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *tagRepository) GetCurrentRevision(ctx context.Context, imageID uint, tagName string) (*models.TagRevision, error) {
+	var revision models.TagRevision
+	err := r.db.Where("image_id = ? AND tag_name = ? AND replaced_at IS NULL", imageID, tagName).
+		Order("first_seen DESC").
+		First(&revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
+		return nil, err
 	}
+	return &revision, nil
+}
 
-	if lastErr != nil {
-		log.Printf("Warning: All deletion attempts failed. Database updated but registry cleanup failed: %v", lastErr)
+func (r *tagRepository) GetLatestRevision(ctx context.Context, imageID uint, tagName string) (*models.TagRevision, error) {
+	var revision models.TagRevision
+	err := r.db.Where("image_id = ? AND tag_name = ?", imageID, tagName).
+		Order("first_seen DESC").
+		First(&revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
 	}
+	return &revision, nil
+}
 
-	return nil // Database was updated successfully even if registry deletion failed
+func (r *tagRepository) ListRevisions(ctx context.Context, repoName, imageName, tagName string) ([]models.TagRevision, error) {
+	var revisions []models.TagRevision
+	err := r.revisionsOf(repoName, imageName, tagName).
+		Order("tag_revisions.first_seen DESC").
+		Find(&revisions).Error
+	return revisions, err
 }
 
-// Helper function to get manifest digest for a tag
-func getManifestDigestForTag(ctx context.Context, baseURL, username, password, repository, tag string) string {
-	url := fmt.Sprintf("%s/v2/%s/manifests/%s", baseURL, repository, tag)
+func (r *tagRepository) GetRevision(ctx context.Context, repoName, imageName, tagName string, id uint) (*models.TagRevision, error) {
+	var revision models.TagRevision
+	err := r.revisionsOf(repoName, imageName, tagName).
+		Where("tag_revisions.id = ?", id).
+		First(&revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (r *tagRepository) GetRevisionAt(ctx context.Context, repoName, imageName, tagName string, at time.Time) (*models.TagRevision, error) {
+	var revision models.TagRevision
+	err := r.revisionsOf(repoName, imageName, tagName).
+		Where("tag_revisions.first_seen <= ?", at).
+		Where("tag_revisions.replaced_at IS NULL OR tag_revisions.replaced_at > ?", at).
+		Order("tag_revisions.first_seen DESC").
+		First(&revision).Error
 	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return ""
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
 	}
+	return &revision, nil
+}
+
+// revisionsOf selects the revisions of a tag by repository, image and tag name
+func (r *tagRepository) revisionsOf(repoName, imageName, tagName string) *gorm.DB {
+	return r.db.Joins("JOIN images ON images.id = tag_revisions.image_id").
+		Joins("JOIN repositories ON repositories.id = images.repository_id").
+		Where("repositories.name = ? AND images.name = ? AND tag_revisions.tag_name = ?", repoName, imageName, tagName)
+}
 
-	// Accept all manifest types to be thorough
-	req.Header.Add("Accept",
-		"application/vnd.docker.distribution.manifest.v2+json,"+
-			"application/vnd.oci.image.manifest.v1+json,"+
-			"application/vnd.docker.distribution.manifest.list.v2+json,"+
-			"application/vnd.oci.image.index.v1+json")
+// deleteTagsCascade permanently removes tags together with their metadata,
+// platforms and layers, and ends their current revision. Rows are hard
+// deleted because the database only mirrors the registry.
+func deleteTagsCascade(tx *gorm.DB, tagIDs []uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
 
-	if username != "" && password != "" {
-		req.SetBasicAuth(username, password)
+	if err := deleteTagContents(tx, tagIDs); err != nil {
+		return err
 	}
 
-	client := &http.Client{Timeout: time.Minute}
-	resp, err := client.Do(req)
+	// Revisions are kept, the current one ends with the tag
+	err := tx.Model(&models.TagRevision{}).
+		Where("replaced_at IS NULL").
+		Where("EXISTS (SELECT 1 FROM tags WHERE tags.id IN ? AND tags.image_id = tag_revisions.image_id AND tags.name = tag_revisions.tag_name)", tagIDs).
+		Update("replaced_at", time.Now()).Error
 	if err != nil {
-		log.Printf("Failed to get manifest: %v", err)
-		return ""
+		return fmt.Errorf("failed to end tag revisions: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Extract body content from response for digest calculation
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	bodyContent := string(bodyBytes)
+	if err := tx.Unscoped().Where("id IN ?", tagIDs).Delete(&models.Tag{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tags: %w", err)
+	}
 
-	// Get the proper digest for deletion - check header first, then calculate if needed
-	digestToDelete := utils.GetCorrectDeleteDigest(
-		resp.Header.Get("Docker-Content-Digest"),
-		bodyContent,
-	)
+	return nil
+}
 
-	log.Printf("Using digest for deletion: %s", digestToDelete)
+// deleteTagContents removes the metadata, platforms, layers and history of tags
+func deleteTagContents(tx *gorm.DB, tagIDs []uint) error {
+	platformIDs := tx.Unscoped().Model(&models.TagPlatform{}).Select("id").Where("tag_id IN ?", tagIDs)
+	metadataIDs := tx.Unscoped().Model(&models.TagMetadata{}).Select("id").
+		Where("tag_id IN ? OR tag_platform_id IN (?)", tagIDs, platformIDs)
+
+	if err := tx.Unscoped().Where("tag_metadata_id IN (?)", metadataIDs).Delete(&models.ImageLayer{}).Error; err != nil {
+		return fmt.Errorf("failed to delete layers: %w", err)
+	}
+	if err := tx.Unscoped().Where("tag_metadata_id IN (?)", metadataIDs).Delete(&models.HistoryEntry{}).Error; err != nil {
+		return fmt.Errorf("failed to delete history: %w", err)
+	}
+	if err := tx.Unscoped().Where("tag_id IN ? OR tag_platform_id IN (?)", tagIDs, platformIDs).Delete(&models.TagMetadata{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tag metadata: %w", err)
+	}
+	if err := tx.Unscoped().Where("tag_id IN ?", tagIDs).Delete(&models.TagPlatform{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tag platforms: %w", err)
+	}
 
-	return digestToDelete
+	return nil
 }
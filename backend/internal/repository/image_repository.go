@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
 )
@@ -10,7 +11,26 @@ import (
 type ImageRepository interface {
 	ListImages(ctx context.Context, repoName string) ([]models.Image, error)
 	GetImage(ctx context.Context, repoName, imageName string) (*models.Image, error)
+	// GetImageByPath returns the image synced from a registry path, without its tags
+	GetImageByPath(ctx context.Context, fullName string) (*models.Image, error)
 	CreateImage(ctx context.Context, image *models.Image) error
 	UpdateImage(ctx context.Context, image *models.Image) error
-	DeleteImage(ctx context.Context, repoName, imageName string) error
+	// SetLastSynced marks an image as seen by a sync, unless a later sync marked it already
+	SetLastSynced(ctx context.Context, imageID uint, syncedAt time.Time) error
+	// IncrementPullCount adds n pulls to an image, it is a no-op for unknown images
+	IncrementPullCount(ctx context.Context, repoName, imageName string, n int) error
+	// RemoveImage permanently removes an image with all its tags from the database only
+	RemoveImage(ctx context.Context, imageID uint) error
+	// DeleteStaleImages removes images within scope not synced since the given time, with all their tags
+	DeleteStaleImages(ctx context.Context, before time.Time, scope ImageScope) ([]models.Image, error)
+	// FindImages returns the images within scope with the metadata and platforms of their tags
+	FindImages(ctx context.Context, scope ImageScope) ([]models.Image, error)
+}
+
+// ImageScope narrows a query to the images of one namespace, or to all
+// namespaces but some. The zero value matches every image.
+type ImageScope struct {
+	Namespace         string
+	Image             string // Narrows Namespace down to a single image
+	ExcludeNamespaces []string
 }
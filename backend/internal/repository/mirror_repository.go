@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// MirrorRepository handles database operations for mirrors and the tags they
+// mirrored
+type MirrorRepository interface {
+	ListMirrors(ctx context.Context) ([]models.Mirror, error)
+	// GetMirror returns a mirror with its mirrored tags, nil if it doesn't exist
+	GetMirror(ctx context.Context, id uint) (*models.Mirror, error)
+	// FindMirror returns the mirror into a local repository, nil if it has none
+	FindMirror(ctx context.Context, target string) (*models.Mirror, error)
+	// SaveMirror creates a mirror or updates its settings and next run
+	SaveMirror(ctx context.Context, mirror *models.Mirror) error
+	// DeleteMirror deletes a mirror and its mirrored tags
+	DeleteMirror(ctx context.Context, id uint) error
+	// UpdateMirrorState stores when a mirror last ran and runs next
+	UpdateMirrorState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error
+	// UpdateMirrorResult stores how the last run of a mirror went
+	UpdateMirrorResult(ctx context.Context, id uint, status, message string) error
+	// SaveMirroredTag creates or updates the record of a mirrored tag
+	SaveMirroredTag(ctx context.Context, tag *models.MirroredTag) error
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// RegistryRepository handles database operations for the registries images
+// can be copied between
+type RegistryRepository interface {
+	ListRegistries(ctx context.Context) ([]models.Registry, error)
+	// GetRegistry returns a registry by name, nil if it doesn't exist
+	GetRegistry(ctx context.Context, name string) (*models.Registry, error)
+	// SaveRegistry creates a registry or updates its URL and credentials
+	SaveRegistry(ctx context.Context, registry *models.Registry) error
+	DeleteRegistry(ctx context.Context, name string) error
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// RetentionRuleRepository handles database operations for retention rules
+type RetentionRuleRepository interface {
+	ListRules(ctx context.Context) ([]models.RetentionRule, error)
+	// GetRule returns a rule, nil if it doesn't exist
+	GetRule(ctx context.Context, id uint) (*models.RetentionRule, error)
+	// FindRule returns the rule of a namespace or image, nil if it has none
+	FindRule(ctx context.Context, namespace, image string) (*models.RetentionRule, error)
+	// SaveRule creates a rule or updates its settings and next run
+	SaveRule(ctx context.Context, rule *models.RetentionRule) error
+	DeleteRule(ctx context.Context, id uint) error
+	// UpdateRuleState stores when a rule last ran and runs next
+	UpdateRuleState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// SyncRunRepository handles database operations for the sync run history
+type SyncRunRepository interface {
+	ListRuns(ctx context.Context, page, limit int) ([]models.SyncRun, int64, error)
+	GetRun(ctx context.Context, id uint) (*models.SyncRun, error)
+	CreateRun(ctx context.Context, run *models.SyncRun) error
+	UpdateRun(ctx context.Context, run *models.SyncRun) error
+	// PruneRuns deletes all but the newest keep runs of full syncs, or of
+	// targeted syncs, so either kind can't push the other out of the history
+	PruneRuns(ctx context.Context, keep int, targeted bool) error
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// SyncScheduleRepository handles database operations for sync schedules
+type SyncScheduleRepository interface {
+	ListSchedules(ctx context.Context) ([]models.SyncSchedule, error)
+	GetSchedule(ctx context.Context, namespace string) (*models.SyncSchedule, error)
+	// SaveSchedule creates the schedule of a namespace or updates its cron
+	// expression and next run
+	SaveSchedule(ctx context.Context, schedule *models.SyncSchedule) error
+	DeleteSchedule(ctx context.Context, namespace string) error
+	// UpdateScheduleState stores when a schedule last ran and runs next
+	UpdateScheduleState(ctx context.Context, id uint, lastRun, nextRun *time.Time) error
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// TagProtectionRepository handles database operations for tag protections
+// and the violations the sync records
+type TagProtectionRepository interface {
+	// ListProtections returns the protections of a namespace, of all namespaces if it is empty
+	ListProtections(ctx context.Context, namespace string) ([]models.TagProtection, error)
+	// GetProtection returns a protection, nil if it doesn't exist
+	GetProtection(ctx context.Context, id uint) (*models.TagProtection, error)
+	// FindProtection returns the protection of a namespace with a pattern, nil if there is none
+	FindProtection(ctx context.Context, namespace, pattern string) (*models.TagProtection, error)
+	CreateProtection(ctx context.Context, protection *models.TagProtection) error
+	DeleteProtection(ctx context.Context, id uint) error
+	// ListViolations returns a page of the violations of a namespace, of all namespaces if it is empty, newest first
+	ListViolations(ctx context.Context, namespace string, page, limit int) ([]models.ProtectionViolation, int64, error)
+	RecordViolation(ctx context.Context, violation *models.ProtectionViolation) error
+}
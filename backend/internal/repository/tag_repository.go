@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/ofkm/svelocker-ui/backend/internal/models"
 )
@@ -12,5 +13,27 @@ type TagRepository interface {
 	GetTag(ctx context.Context, repoName, imageName, tagName string) (*models.Tag, error)
 	CreateTag(ctx context.Context, tag *models.Tag) error
 	UpdateTag(ctx context.Context, tag *models.Tag) error
-	DeleteTag(ctx context.Context, repoName, imageName, tagName string) error
+	// RemoveTag deletes a tag with its metadata and layers from the database only
+	RemoveTag(ctx context.Context, tagID uint) error
+	// ExclusiveLayerSize returns the total size of the layers used by the given
+	// tags and by no other tag, what deleting them frees after garbage collection
+	ExclusiveLayerSize(ctx context.Context, tagIDs []uint) (int64, error)
+	// ListHistory returns the build steps stored for tag or platform metadata in order
+	ListHistory(ctx context.Context, metadataID uint) ([]models.HistoryEntry, error)
+
+	// RecordRevision makes revision the current one of its tag, replacing the
+	// previous one at revision.FirstSeen. Nothing happens if the current one
+	// has the same digest.
+	RecordRevision(ctx context.Context, revision *models.TagRevision) error
+	// GetCurrentRevision returns the revision a tag points to, nil if none was recorded
+	GetCurrentRevision(ctx context.Context, imageID uint, tagName string) (*models.TagRevision, error)
+	// GetLatestRevision returns the newest revision of a tag, also if the tag
+	// was removed since, nil if none was recorded
+	GetLatestRevision(ctx context.Context, imageID uint, tagName string) (*models.TagRevision, error)
+	// ListRevisions returns the revisions of a tag, newest first
+	ListRevisions(ctx context.Context, repoName, imageName, tagName string) ([]models.TagRevision, error)
+	// GetRevision returns a revision of a tag, nil if it doesn't exist
+	GetRevision(ctx context.Context, repoName, imageName, tagName string, id uint) (*models.TagRevision, error)
+	// GetRevisionAt returns the revision a tag pointed to at a point in time, nil if none
+	GetRevisionAt(ctx context.Context, repoName, imageName, tagName string, at time.Time) (*models.TagRevision, error)
 }
@@ -0,0 +1,492 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ErrInvalidBulkDelete is returned for bulk deletes that select nothing or
+// have an invalid selector
+var ErrInvalidBulkDelete = errors.New("invalid bulk delete")
+
+// ErrDeletionPlanNotFound is returned for deletion plans that don't exist
+var ErrDeletionPlanNotFound = errors.New("deletion plan not found")
+
+// ErrDeletionPlanRan is returned when running a deletion plan a second time
+var ErrDeletionPlanRan = errors.New("deletion plan already ran")
+
+// BulkDeleteRequest selects the tags of a bulk delete, by reference, by
+// selector or both
+type BulkDeleteRequest struct {
+	Tags          []string     `json:"tags"` // References like "namespace/image:tag"
+	Selector      *TagSelector `json:"selector"`
+	ConfirmShared bool         `json:"confirmShared"` // Also delete tags that share a manifest with a selected tag
+}
+
+// TagSelector selects the stored tags matching all of its criteria
+type TagSelector struct {
+	Repository string `json:"repository"` // Namespace, empty for all namespaces
+	Image      string `json:"image"`      // Image within Repository
+	TagPattern string `json:"tagPattern"` // Regular expression the tag name must match
+	OlderThan  string `json:"olderThan"`  // Minimum age of the image, like "720h" or "30d"
+	LargerThan int64  `json:"largerThan"` // Minimum compressed size in bytes
+}
+
+// tagSelection are the tags of one image picked for deletion
+type tagSelection struct {
+	namespace string
+	image     string
+	repoPath  string
+	tags      []string
+}
+
+// PlanBulkDelete works out which manifests deleting the selected tags takes
+// and stores that as a plan, without deleting anything
+func (s *SyncService) PlanBulkDelete(ctx context.Context, req BulkDeleteRequest) (*models.DeletionPlan, error) {
+	if len(req.Tags) == 0 && req.Selector == nil {
+		return nil, fmt.Errorf("%w: no tags or selector given", ErrInvalidBulkDelete)
+	}
+
+	selections := make(map[string]*tagSelection)
+	add := func(namespace, imageName, repoPath, tagName string) {
+		sel := selections[repoPath]
+		if sel == nil {
+			sel = &tagSelection{namespace: namespace, image: imageName, repoPath: repoPath}
+			selections[repoPath] = sel
+		}
+		if !slices.Contains(sel.tags, tagName) {
+			sel.tags = append(sel.tags, tagName)
+		}
+	}
+
+	for _, ref := range req.Tags {
+		repoPath, tagName, ok := strings.Cut(ref, ":")
+		if !ok || repoPath == "" || tagName == "" {
+			return nil, fmt.Errorf("%w: %q is not a namespace/image:tag reference", ErrInvalidBulkDelete, ref)
+		}
+		namespace, imageName := utils.SplitRepositoryPath(repoPath)
+		repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+		if err != nil {
+			return nil, err
+		}
+		add(namespace, imageName, repoPath, tagName)
+	}
+
+	if req.Selector != nil {
+		images, err := s.selectTags(ctx, req.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			repoPath := cmp.Or(image.FullName, utils.JoinRepositoryPath(image.namespace, image.Name))
+			for _, tag := range image.Tags {
+				add(image.namespace, image.Name, repoPath, tag.Name)
+			}
+		}
+	}
+
+	sorted := make([]tagSelection, 0, len(selections))
+	for _, repoPath := range slices.Sorted(maps.Keys(selections)) {
+		sorted = append(sorted, *selections[repoPath])
+	}
+
+	plan, err := s.planDeletion(ctx, models.DeletionSourceBulk, sorted, req.ConfirmShared)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.CreatePlan(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to store deletion plan: %w", err)
+	}
+	return plan, nil
+}
+
+// selectedImage is a stored image with the tags a selector matched
+type selectedImage struct {
+	models.Image
+	namespace string
+}
+
+// selectTags returns the stored images with the tags matching a selector
+func (s *SyncService) selectTags(ctx context.Context, selector *TagSelector) ([]selectedImage, error) {
+	if *selector == (TagSelector{}) {
+		return nil, fmt.Errorf("%w: the selector is empty", ErrInvalidBulkDelete)
+	}
+	if selector.Image != "" && selector.Repository == "" {
+		return nil, fmt.Errorf("%w: an image needs a repository", ErrInvalidBulkDelete)
+	}
+
+	var pattern *regexp.Regexp
+	if selector.TagPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(selector.TagPattern); err != nil {
+			return nil, fmt.Errorf("%w: invalid tag pattern: %v", ErrInvalidBulkDelete, err)
+		}
+	}
+	var cutoff time.Time
+	if selector.OlderThan != "" {
+		age, err := parseAge(selector.OlderThan)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBulkDelete, err)
+		}
+		cutoff = time.Now().Add(-age)
+	}
+
+	images, err := s.imageRepo.FindImages(ctx, repository.ImageScope{Namespace: selector.Repository, Image: selector.Image})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find images: %w", err)
+	}
+
+	var selected []selectedImage
+	for _, image := range images {
+		namespace, _ := utils.SplitRepositoryPath(image.FullName)
+		image.Tags = slices.DeleteFunc(image.Tags, func(tag models.Tag) bool {
+			if pattern != nil && !pattern.MatchString(tag.Name) {
+				return true
+			}
+			if !cutoff.IsZero() {
+				// Tags of unknown age are never old enough
+				created, ok := imageCreated(tag)
+				if !ok || !created.Before(cutoff) {
+					return true
+				}
+			}
+			return selector.LargerThan > 0 && tagSize(tag) <= selector.LargerThan
+		})
+		if len(image.Tags) > 0 {
+			selected = append(selected, selectedImage{Image: image, namespace: namespace})
+		}
+	}
+	return selected, nil
+}
+
+// tagSize returns the compressed size of a tag, of all platforms for multi-arch tags
+func tagSize(tag models.Tag) int64 {
+	if len(tag.Platforms) == 0 {
+		return tag.Metadata.TotalSize
+	}
+	var size int64
+	for _, p := range tag.Platforms {
+		size += p.Size
+	}
+	return size
+}
+
+// imageCreated returns when the image of a tag was built, if the registry said
+func imageCreated(tag models.Tag) (time.Time, bool) {
+	created, err := time.Parse(time.RFC3339Nano, tag.Metadata.Created)
+	return created, err == nil
+}
+
+// parseAge parses a duration that may also be given in days, like "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(s)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return age, nil
+}
+
+// planDeletion groups the selected tags by the manifest they point to in the
+// registry. Manifests other tags point to as well are only planned for
+// deletion if confirmShared is set, and manifests an index of a kept tag
+// references never are.
+func (s *SyncService) planDeletion(ctx context.Context, source string, selections []tagSelection, confirmShared bool) (*models.DeletionPlan, error) {
+	plan := &models.DeletionPlan{
+		Source:        source,
+		Status:        models.DeletionPlanStatusPlanned,
+		ConfirmShared: confirmShared,
+		Items:         []models.DeletionPlanItem{},
+	}
+
+	var freedTagIDs []uint
+	for _, sel := range selections {
+		items, tagIDs, err := s.planImageDeletion(ctx, sel, confirmShared)
+		if err != nil {
+			return nil, err
+		}
+		plan.Items = append(plan.Items, items...)
+		freedTagIDs = append(freedTagIDs, tagIDs...)
+	}
+
+	var err error
+	if plan.BytesFreed, err = s.tagRepo.ExclusiveLayerSize(ctx, freedTagIDs); err != nil {
+		return nil, fmt.Errorf("failed to compute freed size: %w", err)
+	}
+	return plan, nil
+}
+
+// planImageDeletion plans the deletion of the selected tags of one image. It
+// returns the items and the IDs of the stored tags the planned ones delete.
+func (s *SyncService) planImageDeletion(ctx context.Context, sel tagSelection, confirmShared bool) ([]models.DeletionPlanItem, []uint, error) {
+	tagsByDigest, err := s.resolveTags(ctx, sel.repoPath)
+	if err != nil && !isNotFound(err) {
+		return nil, nil, fmt.Errorf("failed to resolve tags of %s: %w", sel.repoPath, err)
+	}
+
+	stored, err := s.tagRepo.ListTags(ctx, sel.namespace, sel.image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	protection, err := s.loadTagProtection(ctx, sel.namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	storedTags := make(map[string]models.Tag, len(stored))
+	for _, tag := range stored {
+		storedTags[tag.Name] = tag
+	}
+
+	var items []models.DeletionPlanItem
+	found := make(map[string]bool)
+	deleted := make(map[string]bool)
+	for _, digest := range slices.Sorted(maps.Keys(tagsByDigest)) {
+		var picked, shared []string
+		for _, tagName := range tagsByDigest[digest] {
+			if slices.Contains(sel.tags, tagName) {
+				picked = append(picked, tagName)
+				found[tagName] = true
+			} else {
+				shared = append(shared, tagName)
+			}
+		}
+		if len(picked) == 0 {
+			continue
+		}
+
+		item := models.DeletionPlanItem{
+			Namespace:  sel.namespace,
+			Image:      sel.image,
+			Repository: sel.repoPath,
+			Digest:     digest,
+			Tags:       picked,
+			SharedWith: shared,
+			Status:     models.DeletionItemPlanned,
+		}
+		if refs := protection.protected(sel.image, tagsByDigest[digest]); len(refs) > 0 {
+			item.Status = models.DeletionItemProtected
+			item.Error = fmt.Sprintf("protected tags point to the manifest: %s", strings.Join(refs, ", "))
+		} else if len(shared) > 0 && !confirmShared {
+			item.Status = models.DeletionItemShared
+		} else {
+			for _, tagName := range tagsByDigest[digest] {
+				deleted[tagName] = true
+			}
+		}
+		items = append(items, item)
+	}
+
+	// Deleting a platform manifest would break the multi-arch tags whose index lists it
+	referenced := make(map[string]bool)
+	for _, tag := range stored {
+		if deleted[tag.Name] {
+			continue
+		}
+		for _, platform := range tag.Platforms {
+			referenced[platform.ManifestDigest] = true
+		}
+	}
+
+	var freedTagIDs []uint
+	for i := range items {
+		item := &items[i]
+		tagNames := append(slices.Clone(item.Tags), item.SharedWith...)
+		if item.Status == models.DeletionItemPlanned && referenced[item.Digest] {
+			item.Status = models.DeletionItemReferenced
+		}
+
+		var tagIDs []uint
+		for _, tagName := range tagNames {
+			if tag, ok := storedTags[tagName]; ok {
+				tagIDs = append(tagIDs, tag.ID)
+			}
+		}
+		if item.Size, err = s.tagRepo.ExclusiveLayerSize(ctx, tagIDs); err != nil {
+			return nil, nil, fmt.Errorf("failed to compute freed size: %w", err)
+		}
+		if item.Status == models.DeletionItemPlanned {
+			freedTagIDs = append(freedTagIDs, tagIDs...)
+		}
+	}
+
+	for _, tagName := range sel.tags {
+		if !found[tagName] {
+			items = append(items, models.DeletionPlanItem{
+				Namespace:  sel.namespace,
+				Image:      sel.image,
+				Repository: sel.repoPath,
+				Tags:       []string{tagName},
+				Status:     models.DeletionItemNotFound,
+			})
+		}
+	}
+	return items, freedTagIDs, nil
+}
+
+// ListDeletionPlans returns a page of the deletion plans matching filter, newest first
+func (s *SyncService) ListDeletionPlans(ctx context.Context, filter repository.DeletionPlanFilter, page, limit int) ([]models.DeletionPlan, int64, error) {
+	return s.planRepo.ListPlans(ctx, filter, page, limit)
+}
+
+// GetDeletionPlan returns a deletion plan with its items
+func (s *SyncService) GetDeletionPlan(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	plan, err := s.planRepo.GetPlan(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deletion plan: %w", err)
+	}
+	if plan == nil {
+		return nil, ErrDeletionPlanNotFound
+	}
+	return plan, nil
+}
+
+// RunDeletionPlan deletes the planned manifests from the registry. A manifest
+// is only deleted if exactly the planned tags still point to it, so nothing
+// tagged since the plan was made goes with it. Each plan runs once.
+func (s *SyncService) RunDeletionPlan(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	plan, err := s.startDeletionPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.runDeletionPlan(ctx, plan)
+
+	finishedAt := time.Now()
+	plan.FinishedAt = &finishedAt
+	plan.Status = models.DeletionPlanStatusSucceeded
+	if err != nil {
+		plan.Status = models.DeletionPlanStatusFailed
+		plan.Error = err.Error()
+	} else if slices.ContainsFunc(plan.Items, func(item models.DeletionPlanItem) bool {
+		return item.Status == models.DeletionItemFailed || item.Status == models.DeletionItemChanged
+	}) {
+		plan.Status = models.DeletionPlanStatusPartial
+	}
+
+	// Store the outcome even if the client went away, a plan left running
+	// can't be run again
+	if uerr := s.planRepo.UpdatePlan(context.WithoutCancel(ctx), plan); uerr != nil {
+		log.Printf("Failed to store the outcome of deletion plan %d: %v", plan.ID, uerr)
+	}
+	return plan, err
+}
+
+// startDeletionPlan marks a planned deletion plan as running
+func (s *SyncService) startDeletionPlan(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	s.planMu.Lock()
+	defer s.planMu.Unlock()
+
+	plan, err := s.GetDeletionPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.DeletionPlanStatusPlanned {
+		return nil, fmt.Errorf("%w, it is %s", ErrDeletionPlanRan, plan.Status)
+	}
+
+	startedAt := time.Now()
+	plan.Status = models.DeletionPlanStatusRunning
+	plan.StartedAt = &startedAt
+	if err := s.planRepo.UpdatePlan(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update deletion plan: %w", err)
+	}
+	return plan, nil
+}
+
+// runDeletionPlan deletes the planned items of a plan image by image and
+// records the outcome of each. ErrDeleteUnsupported aborts the run.
+func (s *SyncService) runDeletionPlan(ctx context.Context, plan *models.DeletionPlan) error {
+	var repoPaths []string
+	for _, item := range plan.Items {
+		if item.Status == models.DeletionItemPlanned && !slices.Contains(repoPaths, item.Repository) {
+			repoPaths = append(repoPaths, item.Repository)
+		}
+	}
+
+	for _, repoPath := range repoPaths {
+		if err := s.runImageDeletion(ctx, plan, repoPath); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runImageDeletion deletes the planned items of one image of a plan
+func (s *SyncService) runImageDeletion(ctx context.Context, plan *models.DeletionPlan, repoPath string) error {
+	unlock := s.imageLocks.Lock(repoPath)
+	defer unlock()
+
+	tagsByDigest, err := s.resolveTags(ctx, repoPath)
+	if err != nil && !isNotFound(err) {
+		for i := range plan.Items {
+			if item := &plan.Items[i]; item.Repository == repoPath && item.Status == models.DeletionItemPlanned {
+				item.Status = models.DeletionItemFailed
+				item.Error = err.Error()
+			}
+		}
+		return nil
+	}
+
+	namespace, _ := utils.SplitRepositoryPath(repoPath)
+	protection, err := s.loadTagProtection(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		if item.Repository != repoPath || item.Status != models.DeletionItemPlanned {
+			continue
+		}
+
+		expected := slices.Sorted(slices.Values(append(slices.Clone(item.Tags), item.SharedWith...)))
+		if current := tagsByDigest[item.Digest]; !slices.Equal(current, expected) {
+			item.Status = models.DeletionItemChanged
+			item.Error = fmt.Sprintf("the manifest is now tagged as [%s]", strings.Join(current, ", "))
+			continue
+		}
+		// Tags may have been protected since the plan was made
+		if refs := protection.protected(item.Image, expected); len(refs) > 0 {
+			item.Status = models.DeletionItemProtected
+			item.Error = fmt.Sprintf("protected tags point to the manifest: %s", strings.Join(refs, ", "))
+			continue
+		}
+
+		if err := s.registry.DeleteManifest(ctx, repoPath, item.Digest); err != nil {
+			if errors.Is(err, ErrDeleteUnsupported) {
+				return err
+			}
+			item.Status = models.DeletionItemFailed
+			item.Error = err.Error()
+			continue
+		}
+		item.Status = models.DeletionItemDeleted
+
+		if err := s.forgetTags(ctx, item.Namespace, item.Image, expected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
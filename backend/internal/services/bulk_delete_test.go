@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+func TestPlanBulkDelete(t *testing.T) {
+	registry := newFakeRegistry(t)
+	old := `{"created":"2020-01-01T00:00:00Z"}`
+	recent := fmt.Sprintf(`{"created":%q}`, time.Now().Format(time.RFC3339))
+	large := strings.Repeat("x", 1000)
+	registry.pushImage("team/app", "v1", old, "small")
+	registry.pushImage("team/app", "v2", recent, large)
+	registry.pushImage("team/app", "latest", recent, large)
+	registry.pushImage("team/app", "nightly", old, large)
+	registry.pushImage("team/app", "stable", old, large)
+
+	s := newTestSyncService(t, registry.server.URL)
+	ctx := context.Background()
+	if err := s.protectRepo.CreateProtection(ctx, &models.TagProtection{Namespace: "team", Pattern: "stable"}); err != nil {
+		t.Fatalf("failed to protect tag: %v", err)
+	}
+	syncAll(t, s)
+
+	tests := []struct {
+		name string
+		req  BulkDeleteRequest
+		want map[string]string // Tag -> status of its item
+	}{
+		{"tag pattern", BulkDeleteRequest{Selector: &TagSelector{Repository: "team", TagPattern: "^v"}}, map[string]string{
+			"v1": models.DeletionItemPlanned,
+			// latest goes with v2
+			"v2": models.DeletionItemShared,
+		}},
+		{"confirmed shared", BulkDeleteRequest{Selector: &TagSelector{Repository: "team", TagPattern: "^v"}, ConfirmShared: true}, map[string]string{
+			"v1": models.DeletionItemPlanned,
+			"v2": models.DeletionItemPlanned,
+		}},
+		{"older than", BulkDeleteRequest{Selector: &TagSelector{Repository: "team", OlderThan: "30d"}}, map[string]string{
+			"v1": models.DeletionItemPlanned,
+			// nightly goes with the protected stable tag
+			"nightly": models.DeletionItemProtected,
+			"stable":  models.DeletionItemProtected,
+		}},
+		{"larger than", BulkDeleteRequest{Selector: &TagSelector{Repository: "team", LargerThan: 500}}, map[string]string{
+			"v2":      models.DeletionItemPlanned,
+			"latest":  models.DeletionItemPlanned,
+			"nightly": models.DeletionItemProtected,
+			"stable":  models.DeletionItemProtected,
+		}},
+		{"all criteria", BulkDeleteRequest{Selector: &TagSelector{Repository: "team", Image: "app", TagPattern: "^v", OlderThan: "30d", LargerThan: 500}}, map[string]string{}},
+		{"references", BulkDeleteRequest{Tags: []string{"team/app:v1", "team/app:gone"}}, map[string]string{
+			"v1":   models.DeletionItemPlanned,
+			"gone": models.DeletionItemNotFound,
+		}},
+	}
+	for _, tt := range tests {
+		plan, err := s.PlanBulkDelete(ctx, tt.req)
+		if err != nil {
+			t.Errorf("%s: PlanBulkDelete failed: %v", tt.name, err)
+			continue
+		}
+		got := make(map[string]string)
+		for _, item := range plan.Items {
+			for _, tag := range item.Tags {
+				got[tag] = item.Status
+			}
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: planned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Planning deletes nothing
+	for _, tag := range []string{"v1", "v2", "latest", "nightly", "stable"} {
+		if registry.tagDigest("team/app", tag) == "" {
+			t.Errorf("planning deleted %s from the registry", tag)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ErrInvalidRegistry is returned for registries with an invalid name or URL
+var ErrInvalidRegistry = errors.New("invalid registry")
+
+// ErrRegistryNotFound is returned for registry names that aren't configured
+var ErrRegistryNotFound = errors.New("registry not found")
+
+// ErrInvalidCopyJob is returned for copy jobs with an invalid source or
+// target, or a target that is the source itself
+var ErrInvalidCopyJob = errors.New("invalid copy job")
+
+// ErrCopyJobNotFound is returned for copy jobs that don't exist
+var ErrCopyJobNotFound = errors.New("copy job not found")
+
+var registryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// localSyncer is what copies into the local registry need from the sync
+// service: storing what they pushed, and leaving protected tags alone
+type localSyncer interface {
+	SyncImage(ctx context.Context, trigger, namespace, imageName string) (*SyncResult, error)
+	checkTagProtection(ctx context.Context, namespace, imageName string, tagNames []string) error
+}
+
+// CopyService manages the registries images can be copied between and runs
+// copy jobs
+type CopyService struct {
+	copyMu       sync.Mutex // Runs copy jobs one at a time
+	registryRepo repository.RegistryRepository
+	copyRepo     repository.CopyJobRepository
+	registry     *RegistryClient // The local registry
+	local        localSyncer
+	baseCtx      context.Context // Context jobs run in, cancelled by Stop
+	stop         context.CancelFunc
+}
+
+func NewCopyService(
+	registryRepo repository.RegistryRepository,
+	copyRepo repository.CopyJobRepository,
+	registry *RegistryClient,
+	local localSyncer,
+) *CopyService {
+	return &CopyService{
+		registryRepo: registryRepo,
+		copyRepo:     copyRepo,
+		registry:     registry,
+		local:        local,
+		baseCtx:      context.Background(),
+		stop:         func() {},
+	}
+}
+
+// Start fails the jobs a restart interrupted, copy jobs only run in memory
+func (s *CopyService) Start(ctx context.Context) error {
+	s.baseCtx, s.stop = context.WithCancel(ctx)
+
+	if n, err := s.copyRepo.FailUnfinishedJobs(ctx, "interrupted by a restart"); err != nil {
+		return fmt.Errorf("failed to fail interrupted copy jobs: %w", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted copy jobs as failed", n)
+	}
+	return nil
+}
+
+// Stop cancels the running copy job
+func (s *CopyService) Stop() {
+	s.stop()
+}
+
+// RegistryRequest are the settings of a registry images can be copied between
+type RegistryRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"` // Empty keeps the stored password as long as the username stays set
+}
+
+// CopyRequest copies a tag, or every tag of a repository, between registries
+type CopyRequest struct {
+	SourceRegistry   string `json:"sourceRegistry"`                    // Defaults to "local"
+	Source           string `json:"source" binding:"required"`         // "namespace/image" or "namespace/image:tag"
+	TargetRegistry   string `json:"targetRegistry" binding:"required"` // "local" for the registry the UI manages
+	TargetRepository string `json:"targetRepository"`                  // Defaults to the source repository
+}
+
+// ListRegistries returns the registries images can be copied between, other
+// than the local one
+func (s *CopyService) ListRegistries(ctx context.Context) ([]models.Registry, error) {
+	return s.registryRepo.ListRegistries(ctx)
+}
+
+// SaveRegistry creates or updates a registry
+func (s *CopyService) SaveRegistry(ctx context.Context, name string, req RegistryRequest) (*models.Registry, error) {
+	if !registryNamePattern.MatchString(name) || name == models.LocalRegistryName {
+		return nil, fmt.Errorf("%w: %q can't be used as a registry name", ErrInvalidRegistry, name)
+	}
+	url, err := neturl.Parse(req.URL)
+	if err != nil || (url.Scheme != "http" && url.Scheme != "https") || url.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not an http or https URL", ErrInvalidRegistry, req.URL)
+	}
+
+	registry, err := s.registryRepo.GetRegistry(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry: %w", err)
+	}
+	if registry == nil {
+		registry = &models.Registry{Name: name}
+	}
+
+	registry.URL = strings.TrimRight(req.URL, "/")
+	registry.Username = req.Username
+	switch {
+	case req.Username == "":
+		registry.Password = ""
+	case req.Password != "":
+		registry.Password = req.Password
+	}
+
+	if err := s.registryRepo.SaveRegistry(ctx, registry); err != nil {
+		return nil, fmt.Errorf("failed to save registry: %w", err)
+	}
+	return registry, nil
+}
+
+// DeleteRegistry removes a registry. Copy jobs that used it are kept.
+func (s *CopyService) DeleteRegistry(ctx context.Context, name string) error {
+	registry, err := s.registryRepo.GetRegistry(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get registry: %w", err)
+	}
+	if registry == nil {
+		return fmt.Errorf("%s: %w", name, ErrRegistryNotFound)
+	}
+	if err := s.registryRepo.DeleteRegistry(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete registry: %w", err)
+	}
+	return nil
+}
+
+// registryClient returns a client for a configured registry, or for the
+// local one if name is "local"
+func (s *CopyService) registryClient(ctx context.Context, name string) (*RegistryClient, error) {
+	if name == models.LocalRegistryName {
+		return s.registry, nil
+	}
+
+	registry, err := s.registryRepo.GetRegistry(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry: %w", err)
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("%s: %w", name, ErrRegistryNotFound)
+	}
+	return NewRegistryClient(registry.URL, registry.Username, registry.Password), nil
+}
+
+// ListCopyJobs returns a page of the copy jobs, newest first
+func (s *CopyService) ListCopyJobs(ctx context.Context, page, limit int) ([]models.CopyJob, int64, error) {
+	return s.copyRepo.ListJobs(ctx, page, limit)
+}
+
+// GetCopyJob returns a copy job
+func (s *CopyService) GetCopyJob(ctx context.Context, id uint) (*models.CopyJob, error) {
+	job, err := s.copyRepo.GetJob(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get copy job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrCopyJobNotFound
+	}
+	return job, nil
+}
+
+// StartCopy queues a copy job and runs it in the background. Jobs run one at
+// a time, in the order they were queued.
+func (s *CopyService) StartCopy(ctx context.Context, req CopyRequest) (*models.CopyJob, error) {
+	job := &models.CopyJob{
+		SourceRegistry: cmp.Or(req.SourceRegistry, models.LocalRegistryName),
+		TargetRegistry: req.TargetRegistry,
+		Status:         models.CopyJobStatusQueued,
+	}
+
+	// A tag follows the last colon, as long as no slash comes after it
+	job.SourceRepository = strings.Trim(req.Source, "/")
+	if i := strings.LastIndex(job.SourceRepository, ":"); i >= 0 && !strings.Contains(job.SourceRepository[i:], "/") {
+		job.SourceRepository, job.Tag = job.SourceRepository[:i], job.SourceRepository[i+1:]
+		if !tagNamePattern.MatchString(job.Tag) {
+			return nil, fmt.Errorf("%w: %q is not a valid tag", ErrInvalidCopyJob, job.Tag)
+		}
+	}
+	job.TargetRepository = strings.Trim(cmp.Or(req.TargetRepository, job.SourceRepository), "/")
+	for _, repoPath := range []string{job.SourceRepository, job.TargetRepository} {
+		if !repositoryNamePattern.MatchString(repoPath) {
+			return nil, fmt.Errorf("%w: %q is not a valid repository", ErrInvalidCopyJob, repoPath)
+		}
+	}
+	if job.SourceRegistry == job.TargetRegistry && job.SourceRepository == job.TargetRepository {
+		return nil, fmt.Errorf("%w: the target is the source itself", ErrInvalidCopyJob)
+	}
+
+	source, err := s.registryClient(ctx, job.SourceRegistry)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.registryClient(ctx, job.TargetRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.copyRepo.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create copy job: %w", err)
+	}
+
+	queued := *job
+	go s.runCopyJob(&queued, source, target)
+	return job, nil
+}
+
+// runCopyJob copies the tags of a job one by one, storing its progress after
+// each. A tag that fails doesn't stop the others.
+func (s *CopyService) runCopyJob(job *models.CopyJob, source, target *RegistryClient) {
+	s.copyMu.Lock()
+	defer s.copyMu.Unlock()
+
+	ctx := s.baseCtx
+
+	startedAt := time.Now()
+	job.Status = models.CopyJobStatusRunning
+	job.StartedAt = &startedAt
+	s.updateCopyJob(ctx, job)
+
+	copier := &manifestCopier{
+		source:     source,
+		target:     target,
+		sourcePath: job.SourceRepository,
+		targetPath: job.TargetRepository,
+		mount:      job.SourceRegistry == job.TargetRegistry,
+	}
+	if job.TargetRegistry == models.LocalRegistryName {
+		copier.local = s.local
+	}
+
+	err := s.copyTags(ctx, job, copier)
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	switch {
+	case err != nil:
+		job.Status = models.CopyJobStatusFailed
+		job.Error = err.Error()
+	case len(job.Errors) == 0:
+		job.Status = models.CopyJobStatusSucceeded
+	case job.TagsCopied+job.TagsSkipped == 0:
+		job.Status = models.CopyJobStatusFailed
+	default:
+		job.Status = models.CopyJobStatusPartial
+	}
+	// The job ends even if the service is stopping
+	s.updateCopyJob(context.WithoutCancel(ctx), job)
+	log.Printf("Copy job %d %s: %d tags copied, %d skipped, %d failed, %d bytes transferred",
+		job.ID, job.Status, job.TagsCopied, job.TagsSkipped, len(job.Errors), job.BytesTransferred)
+
+	if job.TargetRegistry == models.LocalRegistryName && job.TagsCopied > 0 {
+		namespace, imageName := utils.SplitRepositoryPath(job.TargetRepository)
+		if _, err := s.local.SyncImage(ctx, models.SyncTriggerCopy, namespace, imageName); err != nil {
+			log.Printf("Failed to sync copied image %s: %v", job.TargetRepository, err)
+		}
+	}
+}
+
+// copyTags copies the tag of a job, or every tag of its source repository
+func (s *CopyService) copyTags(ctx context.Context, job *models.CopyJob, copier *manifestCopier) error {
+	tags := []string{job.Tag}
+	if job.Tag == "" {
+		var err error
+		if tags, err = copier.source.ListTags(ctx, job.SourceRepository); err != nil {
+			return fmt.Errorf("failed to list tags of %s: %w", job.SourceRepository, err)
+		}
+	}
+
+	for _, tag := range tags {
+		_, copied, err := copier.copyTag(ctx, tag)
+		switch {
+		case err != nil:
+			job.Errors = append(job.Errors, fmt.Sprintf("%s: %v", tag, err))
+		case copied:
+			job.TagsCopied++
+		default:
+			job.TagsSkipped++
+		}
+
+		job.BlobsCopied = copier.copied + copier.mounted
+		job.BlobsSkipped = copier.skipped
+		job.BytesTransferred = copier.bytes
+		s.updateCopyJob(ctx, job)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateCopyJob stores the progress of a job, failures are only logged
+func (s *CopyService) updateCopyJob(ctx context.Context, job *models.CopyJob) {
+	if err := s.copyRepo.UpdateJob(ctx, job); err != nil {
+		log.Printf("Failed to update copy job %d: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	gormrepo "github.com/ofkm/svelocker-ui/backend/internal/repository/gorm"
+)
+
+func TestCopyTagStreamsBlobsAcrossRegistries(t *testing.T) {
+	source, target := newFakeRegistry(t), newFakeRegistry(t)
+	digest := source.pushImage("team/app", "v1", `{"os":"linux"}`, "first layer", "second layer")
+
+	copier := &manifestCopier{
+		source:     source.client(),
+		target:     target.client(),
+		sourcePath: "team/app",
+		targetPath: "backup/app",
+	}
+	got, copied, err := copier.copyTag(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("copyTag failed: %v", err)
+	}
+	if !copied || got != digest {
+		t.Fatalf("copyTag = %q, %v; want %q, true", got, copied, digest)
+	}
+
+	if d := target.tagDigest("backup/app", "v1"); d != digest {
+		t.Errorf("target tag points to %q, want %q", d, digest)
+	}
+	for _, content := range []string{`{"os":"linux"}`, "first layer", "second layer"} {
+		if !target.hasBlob("backup/app", fakeDigest([]byte(content))) {
+			t.Errorf("target is missing blob %q", content)
+		}
+	}
+
+	wantBytes := int64(len(`{"os":"linux"}` + "first layer" + "second layer"))
+	if copier.copied != 3 || copier.skipped != 0 || copier.bytes != wantBytes {
+		t.Errorf("copied %d, skipped %d, %d bytes; want 3, 0, %d", copier.copied, copier.skipped, copier.bytes, wantBytes)
+	}
+	// Blobs are uploaded with their size, not buffered into chunks
+	if n := target.counters().uploadLength; n != wantBytes {
+		t.Errorf("uploads announced %d bytes, want %d", n, wantBytes)
+	}
+
+	// The target has the digest now, a second copy does nothing
+	uploads := target.counters().uploads
+	if _, copied, err := copier.copyTag(context.Background(), "v1"); err != nil || copied {
+		t.Fatalf("second copyTag = %v, %v; want no copy", copied, err)
+	}
+	if n := target.counters().uploads - uploads; n != 0 {
+		t.Errorf("second copy uploaded %d blobs", n)
+	}
+}
+
+func TestCopyTagSkipsBlobsTheTargetHas(t *testing.T) {
+	source, target := newFakeRegistry(t), newFakeRegistry(t)
+	source.pushImage("team/app", "v1", `{"os":"linux"}`, "shared layer", "new layer")
+	target.pushBlob("team/app", []byte("shared layer"))
+
+	copier := &manifestCopier{
+		source:     source.client(),
+		target:     target.client(),
+		sourcePath: "team/app",
+		targetPath: "team/app",
+	}
+	if _, _, err := copier.copyTag(context.Background(), "v1"); err != nil {
+		t.Fatalf("copyTag failed: %v", err)
+	}
+
+	if copier.skipped != 1 || copier.copied != 2 {
+		t.Errorf("skipped %d, copied %d blobs; want 1, 2", copier.skipped, copier.copied)
+	}
+	if n := source.counters().blobGets; n != 2 {
+		t.Errorf("downloaded %d blobs from the source, want 2", n)
+	}
+	if want := int64(len(`{"os":"linux"}` + "new layer")); copier.bytes != want {
+		t.Errorf("transferred %d bytes, want %d", copier.bytes, want)
+	}
+}
+
+func TestCopyTagKeepsIndexDigest(t *testing.T) {
+	source, target := newFakeRegistry(t), newFakeRegistry(t)
+	index := source.pushIndex("team/app", "multi", "linux/amd64", "linux/arm64")
+
+	copier := &manifestCopier{
+		source:     source.client(),
+		target:     target.client(),
+		sourcePath: "team/app",
+		targetPath: "team/app",
+	}
+	digest, _, err := copier.copyTag(context.Background(), "multi")
+	if err != nil {
+		t.Fatalf("copyTag failed: %v", err)
+	}
+
+	if digest != index || target.tagDigest("team/app", "multi") != index {
+		t.Errorf("copied index as %q, target has %q; want %q", digest, target.tagDigest("team/app", "multi"), index)
+	}
+	manifest, err := target.client().GetManifest(context.Background(), "team/app", index)
+	if err != nil {
+		t.Fatalf("failed to get copied index: %v", err)
+	}
+	for _, m := range manifest.Manifests {
+		if !target.hasManifest("team/app", m.Digest) {
+			t.Errorf("target is missing platform manifest %s", m.Digest)
+		}
+	}
+}
+
+func TestCopyTagKeepsProtectedTags(t *testing.T) {
+	source, target := newFakeRegistry(t), newFakeRegistry(t)
+	source.pushImage("team/app", "stable", `{"os":"linux"}`, "new layer")
+	current := target.pushImage("team/app", "stable", `{"os":"linux"}`, "old layer")
+
+	copier := &manifestCopier{
+		source:     source.client(),
+		target:     target.client(),
+		sourcePath: "team/app",
+		targetPath: "team/app",
+		local:      &fakeLocal{protected: map[string]bool{"stable": true}},
+	}
+	_, _, err := copier.copyTag(context.Background(), "stable")
+
+	var protectedErr *ProtectedTagError
+	if !errors.As(err, &protectedErr) {
+		t.Fatalf("copyTag = %v, want a ProtectedTagError", err)
+	}
+	if d := target.tagDigest("team/app", "stable"); d != current {
+		t.Errorf("protected tag was overwritten with %q", d)
+	}
+}
+
+func TestCopyTagFailsWhenTheTargetTagIsUnknown(t *testing.T) {
+	source, target := newFakeRegistry(t), newFakeRegistry(t)
+	source.pushImage("team/app", "stable", `{"os":"linux"}`, "new layer")
+	current := target.pushImage("team/app", "stable", `{"os":"linux"}`, "old layer")
+
+	// The target can't tell whether it has the tag, which may be protected
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/manifests/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		target.serve(w, r)
+	}))
+	t.Cleanup(failing.Close)
+
+	copier := &manifestCopier{
+		source:     source.client(),
+		target:     NewRegistryClient(failing.URL, "", ""),
+		sourcePath: "team/app",
+		targetPath: "team/app",
+		local:      &fakeLocal{protected: map[string]bool{"stable": true}},
+	}
+	if _, copied, err := copier.copyTag(context.Background(), "stable"); err == nil || copied {
+		t.Fatalf("copyTag = %v, %v; want an error", copied, err)
+	}
+	if d := target.tagDigest("team/app", "stable"); d != current {
+		t.Errorf("tag was overwritten with %q", d)
+	}
+}
+
+func newTestCopyService(t *testing.T, local *fakeRegistry) (*CopyService, *fakeLocal) {
+	t.Helper()
+	db := openTestDB(t, &models.Registry{}, &models.CopyJob{})
+	syncer := &fakeLocal{}
+	svc := NewCopyService(gormrepo.NewRegistryRepository(db), gormrepo.NewCopyJobRepository(db), local.client(), syncer)
+	if err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start copy service: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc, syncer
+}
+
+// waitForCopyJob waits until a copy job finished and returns it
+func waitForCopyJob(t *testing.T, svc *CopyService, id uint) *models.CopyJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := svc.GetCopyJob(context.Background(), id)
+		if err != nil {
+			t.Fatalf("failed to get copy job: %v", err)
+		}
+		if job.FinishedAt != nil {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("copy job %d is still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartCopyRecordsJobProgress(t *testing.T) {
+	remote, local := newFakeRegistry(t), newFakeRegistry(t)
+	remote.pushImage("team/app", "v1", `{"tag":"v1"}`, "base layer", "v1 layer")
+	remote.pushImage("team/app", "v2", `{"tag":"v2"}`, "base layer", "v2 layer")
+
+	svc, syncer := newTestCopyService(t, local)
+	ctx := context.Background()
+	if _, err := svc.SaveRegistry(ctx, "remote", RegistryRequest{URL: remote.server.URL}); err != nil {
+		t.Fatalf("failed to save registry: %v", err)
+	}
+
+	job, err := svc.StartCopy(ctx, CopyRequest{SourceRegistry: "remote", Source: "team/app", TargetRegistry: models.LocalRegistryName})
+	if err != nil {
+		t.Fatalf("StartCopy failed: %v", err)
+	}
+	if job.Status != models.CopyJobStatusQueued {
+		t.Errorf("new job is %s, want %s", job.Status, models.CopyJobStatusQueued)
+	}
+
+	job = waitForCopyJob(t, svc, job.ID)
+	if job.Status != models.CopyJobStatusSucceeded || len(job.Errors) != 0 {
+		t.Fatalf("job %s with errors %v, want %s", job.Status, job.Errors, models.CopyJobStatusSucceeded)
+	}
+	if job.TagsCopied != 2 || job.BlobsCopied != 5 || job.BlobsSkipped != 1 {
+		t.Errorf("copied %d tags and %d blobs, skipped %d blobs; want 2, 5, 1", job.TagsCopied, job.BlobsCopied, job.BlobsSkipped)
+	}
+	if n := local.counters().uploadLength; job.BytesTransferred == 0 || job.BytesTransferred != n {
+		t.Errorf("job transferred %d bytes, the registry received %d", job.BytesTransferred, n)
+	}
+
+	// A tag that doesn't exist fails the job, nothing was copied. Jobs run
+	// one at a time, so the first one has synced the copied image by now.
+	job, err = svc.StartCopy(ctx, CopyRequest{SourceRegistry: "remote", Source: "team/app:missing", TargetRegistry: models.LocalRegistryName})
+	if err != nil {
+		t.Fatalf("StartCopy failed: %v", err)
+	}
+	job = waitForCopyJob(t, svc, job.ID)
+	if job.Status != models.CopyJobStatusFailed || len(job.Errors) != 1 {
+		t.Errorf("job %s with errors %v, want %s with one error", job.Status, job.Errors, models.CopyJobStatusFailed)
+	}
+	if synced := syncer.syncedImages(); !slices.Equal(synced, []string{"team/app"}) {
+		t.Errorf("synced %v after the copies, want [team/app]", synced)
+	}
+}
+
+func TestStartCopyRejectsInvalidJobs(t *testing.T) {
+	svc, _ := newTestCopyService(t, newFakeRegistry(t))
+	ctx := context.Background()
+
+	for _, req := range []CopyRequest{
+		{Source: "team/app", TargetRegistry: models.LocalRegistryName},
+		{Source: "team/app:bad tag", TargetRegistry: models.LocalRegistryName, TargetRepository: "other/app"},
+		{Source: "Team/App", TargetRegistry: models.LocalRegistryName, TargetRepository: "other/app"},
+	} {
+		if _, err := svc.StartCopy(ctx, req); !errors.Is(err, ErrInvalidCopyJob) {
+			t.Errorf("StartCopy(%+v) = %v, want ErrInvalidCopyJob", req, err)
+		}
+	}
+
+	_, err := svc.StartCopy(ctx, CopyRequest{Source: "team/app", TargetRegistry: "unknown"})
+	if !errors.Is(err, ErrRegistryNotFound) {
+		t.Errorf("StartCopy to an unknown registry = %v, want ErrRegistryNotFound", err)
+	}
+}
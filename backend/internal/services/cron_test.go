@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNextMatchesDays(t *testing.T) {
+	// Friday 2026-10-16
+	from := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		// Both day fields restricted, either one matches
+		{"0 0 1 * mon", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		// A stepped "*" is unrestricted, both have to match
+		{"0 0 */2 * mon", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * */2", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */2 * *", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next of %q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
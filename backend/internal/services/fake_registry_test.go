@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/config"
+	"gorm.io/gorm"
+)
+
+const (
+	ociManifestType = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexType    = "application/vnd.oci.image.index.v1+json"
+)
+
+// fakeRegistry is an in-memory registry implementing the parts of the
+// distribution API the services use. Blobs are linked per repository like
+// in a real registry, so a blob pushed to one repository isn't visible in
+// another until it is uploaded or mounted there.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	tags      map[string]map[string]string // Repository -> tag -> manifest digest
+	manifests map[string]fakeManifest      // Repository + "@" + digest -> manifest
+	blobs     map[string][]byte            // Digest -> content
+	links     map[string]bool              // Repository + "@" + blob digest
+	stats     fakeStats
+
+	deleteStatus int // Status manifest deletes fail with, unless 0
+}
+
+// fakeStats counts the blob requests a fakeRegistry served
+type fakeStats struct {
+	blobGets     int   // Blobs downloaded
+	uploads      int   // Blobs uploaded
+	uploadLength int64 // Sum of the Content-Length of uploads
+	mounts       int   // Blobs mounted from another repository
+}
+
+type fakeManifest struct {
+	mediaType string
+	body      []byte
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+	f := &fakeRegistry{
+		tags:      make(map[string]map[string]string),
+		manifests: make(map[string]fakeManifest),
+		blobs:     make(map[string][]byte),
+		links:     make(map[string]bool),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// client returns a registry client for the fake
+func (f *fakeRegistry) client() *RegistryClient {
+	return NewRegistryClient(f.server.URL, "", "")
+}
+
+func fakeDigest(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+// pushBlob stores a blob in a repository and returns its digest
+func (f *fakeRegistry) pushBlob(repository string, content []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	digest := fakeDigest(content)
+	f.blobs[digest] = content
+	f.links[repository+"@"+digest] = true
+	return digest
+}
+
+// pushManifest stores a manifest by digest, and under tag unless it is empty
+func (f *fakeRegistry) pushManifest(repository, tag, mediaType string, body []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	digest := fakeDigest(body)
+	f.manifests[repository+"@"+digest] = fakeManifest{mediaType: mediaType, body: body}
+	if tag != "" {
+		if f.tags[repository] == nil {
+			f.tags[repository] = make(map[string]string)
+		}
+		f.tags[repository][tag] = digest
+	}
+	return digest
+}
+
+// pushImage pushes an image whose config and layers have the given content
+// and returns its manifest digest
+func (f *fakeRegistry) pushImage(repository, tag, config string, layers ...string) string {
+	configDigest := f.pushBlob(repository, []byte(config))
+
+	manifest := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     ociManifestType,
+		"config": map[string]any{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"digest":    configDigest,
+			"size":      len(config),
+		},
+	}
+	descriptors := []map[string]any{}
+	for _, layer := range layers {
+		descriptors = append(descriptors, map[string]any{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    f.pushBlob(repository, []byte(layer)),
+			"size":      len(layer),
+		})
+	}
+	manifest["layers"] = descriptors
+
+	body, _ := json.Marshal(manifest)
+	return f.pushManifest(repository, tag, ociManifestType, body)
+}
+
+// pushIndex pushes a multi-arch index over one image per platform and
+// returns the digest of the index
+func (f *fakeRegistry) pushIndex(repository, tag string, platforms ...string) string {
+	manifests := []map[string]any{}
+	for _, platform := range platforms {
+		os, arch, _ := strings.Cut(platform, "/")
+		digest := f.pushImage(repository, "", `{"os":"`+os+`","architecture":"`+arch+`"}`, "layer for "+platform)
+		manifests = append(manifests, map[string]any{
+			"mediaType": ociManifestType,
+			"digest":    digest,
+			"size":      len(f.manifests[repository+"@"+digest].body),
+			"platform":  map[string]string{"os": os, "architecture": arch},
+		})
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     ociIndexType,
+		"manifests":     manifests,
+	})
+	return f.pushManifest(repository, tag, ociIndexType, body)
+}
+
+// counters returns the blob requests served so far
+func (f *fakeRegistry) counters() fakeStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// tagDigest returns the digest a tag points to, empty if it doesn't exist
+func (f *fakeRegistry) tagDigest(repository, tag string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tags[repository][tag]
+}
+
+// hasBlob reports whether a blob is linked into a repository
+func (f *fakeRegistry) hasBlob(repository, digest string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.links[repository+"@"+digest]
+}
+
+// hasManifest reports whether a repository has a manifest with its exact content
+func (f *fakeRegistry) hasManifest(repository, digest string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	manifest, ok := f.manifests[repository+"@"+digest]
+	return ok && fakeDigest(manifest.body) == digest
+}
+
+func (f *fakeRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case path == "_catalog":
+		f.serveCatalog(w)
+	case strings.HasSuffix(path, "/tags/list"):
+		f.serveTags(w, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+		f.serveManifest(w, r, repository, reference)
+	case strings.Contains(path, "/blobs/uploads/"):
+		repository, _, _ := strings.Cut(path, "/blobs/uploads/")
+		f.serveUpload(w, r, repository)
+	case strings.Contains(path, "/blobs/"):
+		repository, digest, _ := strings.Cut(path, "/blobs/")
+		f.serveBlob(w, r, repository, digest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeRegistry) serveCatalog(w http.ResponseWriter) {
+	names := make([]string, 0, len(f.tags))
+	for repository := range f.tags {
+		names = append(names, repository)
+	}
+	sort.Strings(names)
+	json.NewEncoder(w).Encode(map[string]any{"repositories": names})
+}
+
+func (f *fakeRegistry) serveTags(w http.ResponseWriter, repository string) {
+	tags, ok := f.tags[repository]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	json.NewEncoder(w).Encode(map[string]any{"name": repository, "tags": names})
+}
+
+func (f *fakeRegistry) serveManifest(w http.ResponseWriter, r *http.Request, repository, reference string) {
+	if r.Method == http.MethodPut {
+		body, _ := io.ReadAll(r.Body)
+		digest := fakeDigest(body)
+		if strings.HasPrefix(reference, "sha256:") && reference != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !f.referencesLinked(repository, body) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_BLOB_UNKNOWN"}]}`)
+			return
+		}
+		f.manifests[repository+"@"+digest] = fakeManifest{mediaType: r.Header.Get("Content-Type"), body: body}
+		if !strings.HasPrefix(reference, "sha256:") {
+			if f.tags[repository] == nil {
+				f.tags[repository] = make(map[string]string)
+			}
+			f.tags[repository][reference] = digest
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		f.deleteManifest(w, repository, reference)
+		return
+	}
+
+	digest := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		digest = f.tags[repository][reference]
+	}
+	manifest, ok := f.manifests[repository+"@"+digest]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Type", manifest.mediaType)
+	if r.Method == http.MethodGet {
+		w.Write(manifest.body)
+	}
+}
+
+// deleteManifest deletes a manifest by digest along with the tags pointing to it
+func (f *fakeRegistry) deleteManifest(w http.ResponseWriter, repository, digest string) {
+	if f.deleteStatus != 0 {
+		w.WriteHeader(f.deleteStatus)
+		return
+	}
+	if _, ok := f.manifests[repository+"@"+digest]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delete(f.manifests, repository+"@"+digest)
+	for tag, tagDigest := range f.tags[repository] {
+		if tagDigest == digest {
+			delete(f.tags[repository], tag)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// referencesLinked reports whether the repository has everything a pushed
+// manifest references, like a registry checks before accepting it
+func (f *fakeRegistry) referencesLinked(repository string, body []byte) bool {
+	var manifest ManifestResponse
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return false
+	}
+	for _, m := range manifest.Manifests {
+		if _, ok := f.manifests[repository+"@"+m.Digest]; !ok {
+			return false
+		}
+	}
+	for _, blob := range manifestBlobs(&manifest) {
+		if !f.links[repository+"@"+blob] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeRegistry) serveUpload(w http.ResponseWriter, r *http.Request, repository string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodPost:
+		if digest, from := query.Get("mount"), query.Get("from"); digest != "" && f.links[from+"@"+digest] {
+			f.links[repository+"@"+digest] = true
+			f.stats.mounts++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Location", "/v2/"+repository+"/blobs/uploads/session?_state=open")
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		digest := query.Get("digest")
+		if query.Get("_state") != "open" || fakeDigest(content) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.blobs[digest] = content
+		f.links[repository+"@"+digest] = true
+		f.stats.uploads++
+		f.stats.uploadLength += r.ContentLength
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeRegistry) serveBlob(w http.ResponseWriter, r *http.Request, repository, digest string) {
+	content, ok := f.blobs[digest]
+	if !ok || !f.links[repository+"@"+digest] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	if r.Method == http.MethodGet {
+		f.stats.blobGets++
+		w.Write(content)
+	}
+}
+
+// fakeLocal stands in for the sync service behind copies and mirrors into
+// the local registry
+type fakeLocal struct {
+	mu        sync.Mutex
+	synced    []string // Images SyncImage was called for
+	protected map[string]bool
+}
+
+func (l *fakeLocal) SyncImage(ctx context.Context, trigger, namespace, imageName string) (*SyncResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.synced = append(l.synced, namespace+"/"+imageName)
+	return &SyncResult{}, nil
+}
+
+// syncedImages returns the images SyncImage was called for
+func (l *fakeLocal) syncedImages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.synced)
+}
+
+func (l *fakeLocal) checkTagProtection(ctx context.Context, namespace, imageName string, tagNames []string) error {
+	for _, tag := range tagNames {
+		if l.protected[tag] {
+			return &ProtectedTagError{Tags: []string{tag}}
+		}
+	}
+	return nil
+}
+
+// openTestDB returns an empty database with the given models migrated
+func openTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	dbConfig := &config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}
+	db, err := dbConfig.Connect("error")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
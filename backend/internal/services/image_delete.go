@@ -0,0 +1,208 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ManifestDeletion is the outcome of deleting one manifest from the registry.
+// Failures to list a repository are reported without a digest.
+type ManifestDeletion struct {
+	Repository string   `json:"repository"`
+	Digest     string   `json:"digest,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// DeletionReport lists the manifests a delete removed and the ones it couldn't
+type DeletionReport struct {
+	Deleted []ManifestDeletion `json:"deleted"`
+	Failed  []ManifestDeletion `json:"failed"`
+}
+
+func newDeletionReport() *DeletionReport {
+	return &DeletionReport{Deleted: []ManifestDeletion{}, Failed: []ManifestDeletion{}}
+}
+
+// DeleteImage deletes every manifest of an image from the registry. The image
+// is removed from the database once none are left; tags whose manifest
+// couldn't be deleted are kept and listed in the report. Nothing is deleted if
+// the image has protected tags, a *ProtectedTagError is returned instead.
+func (s *SyncService) DeleteImage(ctx context.Context, namespace, imageName string) (*DeletionReport, error) {
+	repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newDeletionReport()
+	if err := s.deleteRepository(ctx, report, namespace, imageName, repoPath); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// DeleteNamespace deletes every manifest of every image in a namespace from
+// the registry, see DeleteImage. Images of the namespace that are only left in
+// the database are removed from it.
+func (s *SyncService) DeleteNamespace(ctx context.Context, namespace string) (*DeletionReport, error) {
+	var repoPaths []string
+	err := s.registry.WalkRepositories(ctx, func(repositories []string) error {
+		for _, repoPath := range repositories {
+			if ns, _ := utils.SplitRepositoryPath(repoPath); ns == namespace {
+				repoPaths = append(repoPaths, repoPath)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	stored, err := s.imageRepo.ListImages(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	if len(repoPaths) == 0 && len(stored) == 0 {
+		return nil, fmt.Errorf("namespace %s: %w", namespace, ErrNotInRegistry)
+	}
+	for _, image := range stored {
+		repoPath := cmp.Or(image.FullName, utils.JoinRepositoryPath(namespace, image.Name))
+		if !slices.Contains(repoPaths, repoPath) {
+			repoPaths = append(repoPaths, repoPath)
+		}
+	}
+
+	if err := s.checkNamespaceProtection(ctx, namespace, repoPaths); err != nil {
+		return nil, err
+	}
+
+	report := newDeletionReport()
+	for _, repoPath := range repoPaths {
+		_, imageName := utils.SplitRepositoryPath(repoPath)
+		err := s.deleteRepository(ctx, report, namespace, imageName, repoPath)
+		switch {
+		case errors.Is(err, ErrNotInRegistry):
+			// Only left in the database, which deleteRepository cleaned up
+		case errors.Is(err, ErrDeleteUnsupported):
+			return nil, err
+		case err != nil:
+			// Includes tags protected since the namespace was checked
+			report.Failed = append(report.Failed, ManifestDeletion{Repository: repoPath, Error: err.Error()})
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// deleteRepository deletes the manifests of one image and records them in
+// report. ErrDeleteUnsupported ends it right away, as no delete can succeed.
+func (s *SyncService) deleteRepository(ctx context.Context, report *DeletionReport, namespace, imageName, repoPath string) error {
+	unlock := s.imageLocks.Lock(repoPath)
+	defer unlock()
+
+	tagsByDigest, err := s.resolveTags(ctx, repoPath)
+	if err != nil {
+		if isNotFound(err) {
+			if err := s.forgetImage(ctx, namespace, imageName); err != nil {
+				return err
+			}
+			return fmt.Errorf("image %s: %w", repoPath, ErrNotInRegistry)
+		}
+		return err
+	}
+
+	var tagNames []string
+	for _, tags := range tagsByDigest {
+		tagNames = append(tagNames, tags...)
+	}
+	slices.Sort(tagNames)
+	if err := s.checkTagProtection(ctx, namespace, imageName, tagNames); err != nil {
+		return err
+	}
+
+	failed := false
+	for _, digest := range slices.Sorted(maps.Keys(tagsByDigest)) {
+		deletion := ManifestDeletion{Repository: repoPath, Digest: digest, Tags: tagsByDigest[digest]}
+
+		if err := s.registry.DeleteManifest(ctx, repoPath, digest); err != nil {
+			if errors.Is(err, ErrDeleteUnsupported) {
+				return err
+			}
+			deletion.Error = err.Error()
+			report.Failed = append(report.Failed, deletion)
+			failed = true
+			continue
+		}
+		report.Deleted = append(report.Deleted, deletion)
+
+		if err := s.forgetTags(ctx, namespace, imageName, deletion.Tags); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return nil
+	}
+	log.Printf("Deleted all manifests of %s", repoPath)
+	return s.forgetImage(ctx, namespace, imageName)
+}
+
+// forgetImage removes an image from the database, and its namespace once
+// that is empty
+func (s *SyncService) forgetImage(ctx context.Context, namespace, imageName string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	image, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+	if err != nil {
+		return fmt.Errorf("failed to get image: %w", err)
+	}
+	if image != nil {
+		if err := s.imageRepo.RemoveImage(ctx, image.ID); err != nil {
+			return fmt.Errorf("failed to remove image %s: %w", imageName, err)
+		}
+	}
+
+	if _, err := s.dockerRepo.DeleteEmptyRepositories(ctx); err != nil {
+		return fmt.Errorf("failed to remove empty namespaces: %w", err)
+	}
+	return nil
+}
+
+// checkNamespaceProtection returns a *ProtectedTagError listing the protected
+// tags of all images of a namespace, so deleting it stops before it starts
+func (s *SyncService) checkNamespaceProtection(ctx context.Context, namespace string, repoPaths []string) error {
+	protection, err := s.loadTagProtection(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if len(protection.compiled) == 0 {
+		return nil
+	}
+
+	var refs []string
+	for _, repoPath := range repoPaths {
+		_, imageName := utils.SplitRepositoryPath(repoPath)
+		err := s.registry.WalkTags(ctx, repoPath, func(tags []string) error {
+			refs = append(refs, protection.protected(imageName, tags)...)
+			return nil
+		})
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to list tags of %s: %w", repoPath, err)
+		}
+	}
+	if len(refs) > 0 {
+		slices.Sort(refs)
+		return &ProtectedTagError{Tags: refs}
+	}
+	return nil
+}
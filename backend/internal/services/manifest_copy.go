@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// manifestCopier pushes everything a manifest references from one repository
+// into another, on the same registry or across registries. Blobs are
+// streamed from source to target without buffering them.
+type manifestCopier struct {
+	source     *RegistryClient
+	target     *RegistryClient
+	sourcePath string
+	targetPath string
+	mount      bool        // Both repositories are on the same registry, blobs are mounted instead of uploaded
+	local      localSyncer // Set when the target is the local registry, whose protected tags are kept
+
+	mounted int   // Blobs linked from the source repository
+	copied  int   // Blobs uploaded to the target
+	skipped int   // Blobs the target already had
+	bytes   int64 // Bytes uploaded to the target
+}
+
+// copyTag copies a single tag, unless the target has it with the same digest
+// already, and returns its digest. Protected tags of the local registry
+// aren't overwritten.
+func (c *manifestCopier) copyTag(ctx context.Context, tag string) (digest string, copied bool, err error) {
+	// Resolving the tag first saves downloading manifests that didn't change
+	digest, err = c.source.HeadManifest(ctx, c.sourcePath, tag)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve tag: %w", err)
+	}
+	// Only a 404 means the target doesn't have the tag, any other failure
+	// could hide a protected tag that would be overwritten
+	current, err := c.target.HeadManifest(ctx, c.targetPath, tag)
+	if err != nil && !isNotFound(err) {
+		return "", false, fmt.Errorf("failed to resolve target tag: %w", err)
+	}
+	exists := err == nil
+	if exists && digest != "" && current == digest {
+		return digest, false, nil
+	}
+	if exists && c.local != nil {
+		namespace, imageName := utils.SplitRepositoryPath(c.targetPath)
+		if err := c.local.checkTagProtection(ctx, namespace, imageName, []string{tag}); err != nil {
+			return "", false, err
+		}
+	}
+
+	manifest, err := c.source.GetManifest(ctx, c.sourcePath, tag)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	if err := c.copyReferences(ctx, manifest); err != nil {
+		return "", false, err
+	}
+	if _, err := c.target.PutManifest(ctx, c.targetPath, tag, manifest.MediaType, manifest.Raw); err != nil {
+		return "", false, fmt.Errorf("failed to push manifest: %w", err)
+	}
+	return manifest.Digest, true, nil
+}
+
+// copyReferences makes sure the target has everything a manifest references:
+// the config and layers of an image, and for an index its platform
+// manifests, which are pushed by digest so the index stays intact.
+func (c *manifestCopier) copyReferences(ctx context.Context, manifest *ManifestResponse) error {
+	if manifest.IsIndex() {
+		for _, m := range manifest.Manifests {
+			child, err := c.source.GetManifest(ctx, c.sourcePath, m.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to get manifest %s: %w", m.Digest, err)
+			}
+			if err := c.copyReferences(ctx, child); err != nil {
+				return err
+			}
+			if _, err := c.target.PutManifest(ctx, c.targetPath, m.Digest, child.MediaType, child.Raw); err != nil {
+				return fmt.Errorf("failed to push manifest %s: %w", m.Digest, err)
+			}
+		}
+		return nil
+	}
+
+	for _, blob := range manifestBlobs(manifest) {
+		if err := c.copyBlob(ctx, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestBlobs returns the blobs an image manifest references in the
+// registry. Foreign layers are downloaded from elsewhere and left out.
+func manifestBlobs(manifest *ManifestResponse) []string {
+	var blobs []string
+	if manifest.Config.Digest != "" {
+		blobs = append(blobs, manifest.Config.Digest)
+	}
+	for _, layer := range manifest.Layers {
+		if strings.Contains(layer.MediaType, "foreign") {
+			continue
+		}
+		blobs = append(blobs, layer.Digest)
+	}
+	return blobs
+}
+
+// copyBlob gets a blob into the target, mounting it if both repositories are
+// on the same registry and uploading it otherwise or if the registry won't
+// mount it. Blobs the target already has are skipped.
+func (c *manifestCopier) copyBlob(ctx context.Context, digest string) error {
+	exists, err := c.target.BlobExists(ctx, c.targetPath, digest)
+	if err != nil {
+		return fmt.Errorf("failed to check blob %s: %w", digest, err)
+	}
+	if exists {
+		c.skipped++
+		return nil
+	}
+
+	if c.mount {
+		mounted, err := c.target.MountBlob(ctx, c.targetPath, digest, c.sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to mount blob %s: %w", digest, err)
+		}
+		if mounted {
+			c.mounted++
+			return nil
+		}
+	}
+
+	content, size, err := c.source.GetBlob(ctx, c.sourcePath, digest)
+	if err != nil {
+		return fmt.Errorf("failed to get blob %s: %w", digest, err)
+	}
+	defer content.Close()
+
+	counted := &countingReader{r: content}
+	if err := c.target.UploadBlob(ctx, c.targetPath, digest, size, counted); err != nil {
+		return fmt.Errorf("failed to upload blob %s: %w", digest, err)
+	}
+	c.copied++
+	c.bytes += counted.n
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ErrInvalidMirror is returned for mirrors with an invalid upstream, pattern
+// or schedule, or a target that already has a mirror
+var ErrInvalidMirror = errors.New("invalid mirror")
+
+// ErrMirrorNotFound is returned for mirrors that don't exist
+var ErrMirrorNotFound = errors.New("mirror not found")
+
+// ErrMirrorRunning is returned when a mirror is started while it still runs
+var ErrMirrorRunning = errors.New("mirror is already running")
+
+// Docker Hub is the default upstream registry, its API lives on another host
+const (
+	dockerHubHost    = "docker.io"
+	dockerHubAPIHost = "registry-1.docker.io"
+)
+
+// MirrorService keeps upstream images mirrored into the local registry
+type MirrorService struct {
+	mu         sync.Mutex
+	mirroring  map[uint]bool // Mirrors with a run in progress
+	mirrorRepo repository.MirrorRepository
+	registry   *RegistryClient // The local registry
+	local      localSyncer
+	baseCtx    context.Context // Context mirrors run in, cancelled by Stop
+	stop       context.CancelFunc
+}
+
+func NewMirrorService(
+	mirrorRepo repository.MirrorRepository,
+	registry *RegistryClient,
+	local localSyncer,
+) *MirrorService {
+	return &MirrorService{
+		mirroring:  make(map[uint]bool),
+		mirrorRepo: mirrorRepo,
+		registry:   registry,
+		local:      local,
+		baseCtx:    context.Background(),
+		stop:       func() {},
+	}
+}
+
+// Start runs mirrors as their schedules come due
+func (s *MirrorService) Start(ctx context.Context) error {
+	s.baseCtx, s.stop = context.WithCancel(ctx)
+
+	go everyMinute(s.baseCtx, func(now time.Time) {
+		s.runDueMirrors(s.baseCtx, now)
+	})
+	return nil
+}
+
+// Stop ends the schedules and cancels running mirrors
+func (s *MirrorService) Stop() {
+	s.stop()
+}
+
+// MirrorRequest are the settings of a mirror
+type MirrorRequest struct {
+	Source   string `json:"source" binding:"required"` // Upstream repository with an optional tag glob like "docker.io/library/postgres:16*"
+	Target   string `json:"target" binding:"required"` // Local repository like "mirror/postgres"
+	Username string `json:"username"`
+	Password string `json:"password"` // Empty keeps the stored password as long as the username stays set
+	Cron     string `json:"cron"`     // Defaults to "manual"
+}
+
+// ListMirrors returns all mirrors, without their mirrored tags
+func (s *MirrorService) ListMirrors(ctx context.Context) ([]models.Mirror, error) {
+	return s.mirrorRepo.ListMirrors(ctx)
+}
+
+// GetMirror returns a mirror with the digests its tags were last mirrored at
+func (s *MirrorService) GetMirror(ctx context.Context, id uint) (*models.Mirror, error) {
+	mirror, err := s.mirrorRepo.GetMirror(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mirror: %w", err)
+	}
+	if mirror == nil {
+		return nil, ErrMirrorNotFound
+	}
+	return mirror, nil
+}
+
+// SaveMirror creates a mirror, or updates the settings of mirror id if it
+// isn't zero, and works out when it runs next
+func (s *MirrorService) SaveMirror(ctx context.Context, id uint, req MirrorRequest) (*models.Mirror, error) {
+	// The tag glob follows the last colon, as long as no slash comes after it
+	upstream, pattern := strings.TrimSpace(req.Source), ""
+	if i := strings.LastIndex(upstream, ":"); i >= 0 && !strings.Contains(upstream[i:], "/") {
+		upstream, pattern = upstream[:i], upstream[i+1:]
+	}
+	if _, _, err := parseUpstream(upstream); err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: invalid tag pattern %q: %v", ErrInvalidMirror, pattern, err)
+	}
+
+	target := strings.Trim(req.Target, "/")
+	if !repositoryNamePattern.MatchString(target) {
+		return nil, fmt.Errorf("%w: %q is not a valid repository", ErrInvalidMirror, target)
+	}
+
+	cron := req.Cron
+	if cron == "" {
+		cron = models.SyncScheduleManual
+	}
+	next, err := nextCronRun(cron, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMirror, err)
+	}
+
+	mirror := &models.Mirror{}
+	if id != 0 {
+		if mirror, err = s.GetMirror(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := s.mirrorRepo.FindMirror(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mirror: %w", err)
+	}
+	if existing != nil && existing.ID != id {
+		return nil, fmt.Errorf("%w: %s already has mirror %d", ErrInvalidMirror, target, existing.ID)
+	}
+
+	mirror.Upstream = upstream
+	mirror.TagPattern = pattern
+	mirror.Target = target
+	mirror.Username = req.Username
+	switch {
+	case req.Username == "":
+		mirror.Password = ""
+	case req.Password != "":
+		mirror.Password = req.Password
+	}
+	mirror.Cron = cron
+	mirror.NextRun = next
+
+	if err := s.mirrorRepo.SaveMirror(ctx, mirror); err != nil {
+		return nil, fmt.Errorf("failed to save mirror: %w", err)
+	}
+	return mirror, nil
+}
+
+// DeleteMirror deletes a mirror. The images it mirrored stay in the registry.
+func (s *MirrorService) DeleteMirror(ctx context.Context, id uint) error {
+	if _, err := s.GetMirror(ctx, id); err != nil {
+		return err
+	}
+	if err := s.mirrorRepo.DeleteMirror(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete mirror: %w", err)
+	}
+	return nil
+}
+
+// StartMirror runs a mirror in the background, GetMirror shows how it went
+func (s *MirrorService) StartMirror(ctx context.Context, id uint) (*models.Mirror, error) {
+	mirror, err := s.GetMirror(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !s.startMirror(id) {
+		return nil, ErrMirrorRunning
+	}
+	mirror.LastRun = &now
+	if err := s.mirrorRepo.UpdateMirrorState(ctx, id, mirror.LastRun, mirror.NextRun); err != nil {
+		log.Printf("Failed to update mirror %d: %v", id, err)
+	}
+	return mirror, nil
+}
+
+// parseUpstream splits an upstream repository like "docker.io/library/postgres"
+// into the URL of its registry and its path there. Without a registry host
+// Docker Hub is assumed, where official images live under "library/".
+// Registries are reached over HTTPS unless the repository starts with
+// "http://".
+func parseUpstream(upstream string) (baseURL, repoPath string, err error) {
+	scheme := "https"
+	if rest, ok := strings.CutPrefix(upstream, "http://"); ok {
+		scheme, upstream = "http", rest
+	} else {
+		upstream = strings.TrimPrefix(upstream, "https://")
+	}
+	upstream = strings.Trim(upstream, "/")
+
+	host := dockerHubHost
+	if first, rest, ok := strings.Cut(upstream, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, upstream = first, rest
+	}
+	if host == dockerHubHost || host == "index."+dockerHubHost {
+		host = dockerHubAPIHost
+		if !strings.Contains(upstream, "/") {
+			upstream = "library/" + upstream
+		}
+	}
+
+	if !repositoryNamePattern.MatchString(upstream) {
+		return "", "", fmt.Errorf("%w: %q is not a valid upstream repository", ErrInvalidMirror, upstream)
+	}
+	return scheme + "://" + host, upstream, nil
+}
+
+// runDueMirrors starts the mirrors whose next run is due
+func (s *MirrorService) runDueMirrors(ctx context.Context, now time.Time) {
+	mirrors, err := s.mirrorRepo.ListMirrors(ctx)
+	if err != nil {
+		log.Printf("Failed to load mirrors: %v", err)
+		return
+	}
+
+	for _, mirror := range mirrors {
+		if mirror.Cron == models.SyncScheduleManual {
+			continue
+		}
+		if mirror.NextRun != nil && mirror.NextRun.After(now) {
+			continue
+		}
+
+		next, err := nextCronRun(mirror.Cron, now)
+		if err != nil {
+			log.Printf("Invalid schedule of mirror %d into %s: %v", mirror.ID, mirror.Target, err)
+			continue
+		}
+
+		lastRun := mirror.LastRun
+		if s.startMirror(mirror.ID) {
+			lastRun = &now
+		} else {
+			log.Printf("Skipping mirror %d, its previous run is still in progress", mirror.ID)
+		}
+
+		if err := s.mirrorRepo.UpdateMirrorState(ctx, mirror.ID, lastRun, next); err != nil {
+			log.Printf("Failed to update mirror %d: %v", mirror.ID, err)
+		}
+	}
+}
+
+// startMirror runs a mirror in the background unless it is running already
+func (s *MirrorService) startMirror(id uint) bool {
+	s.mu.Lock()
+	if s.mirroring[id] {
+		s.mu.Unlock()
+		return false
+	}
+	s.mirroring[id] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.mirroring, id)
+			s.mu.Unlock()
+		}()
+
+		if err := s.runMirror(s.baseCtx, id); err != nil {
+			log.Printf("Mirror %d failed: %v", id, err)
+		}
+	}()
+	return true
+}
+
+// runMirror copies the upstream tags matching the pattern of a mirror whose
+// digest differs from the local one, and records the digest or the failure of
+// every tag. A tag that fails doesn't stop the others.
+func (s *MirrorService) runMirror(ctx context.Context, id uint) error {
+	mirror, err := s.GetMirror(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	copied, failed, err := s.mirrorTags(ctx, mirror)
+
+	status, message := models.MirrorStatusSucceeded, ""
+	switch {
+	case err != nil:
+		status, message = models.MirrorStatusFailed, err.Error()
+	case len(failed) > 0:
+		status = models.MirrorStatusPartial
+		message = fmt.Sprintf("%d tags failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	// The result is stored even if the service is stopping
+	if err := s.mirrorRepo.UpdateMirrorResult(context.WithoutCancel(ctx), id, status, message); err != nil {
+		log.Printf("Failed to update mirror %d: %v", id, err)
+	}
+
+	if copied > 0 {
+		namespace, imageName := utils.SplitRepositoryPath(mirror.Target)
+		if _, err := s.local.SyncImage(ctx, models.SyncTriggerMirror, namespace, imageName); err != nil {
+			log.Printf("Failed to sync mirrored image %s: %v", mirror.Target, err)
+		}
+	}
+	return err
+}
+
+// mirrorTags copies the matching tags of a mirror and returns how many were
+// copied and which failed
+func (s *MirrorService) mirrorTags(ctx context.Context, mirror *models.Mirror) (copied int, failed []string, err error) {
+	baseURL, upstreamPath, err := parseUpstream(mirror.Upstream)
+	if err != nil {
+		return 0, nil, err
+	}
+	copier := &manifestCopier{
+		source:     NewRegistryClient(baseURL, mirror.Username, mirror.Password),
+		target:     s.registry,
+		sourcePath: upstreamPath,
+		targetPath: mirror.Target,
+		local:      s.local,
+	}
+
+	tags, err := copier.source.ListTags(ctx, upstreamPath)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list tags of %s: %w", mirror.Upstream, err)
+	}
+
+	recorded := make(map[string]*models.MirroredTag, len(mirror.Tags))
+	for i := range mirror.Tags {
+		recorded[mirror.Tags[i].Tag] = &mirror.Tags[i]
+	}
+
+	for _, tag := range tags {
+		if mirror.TagPattern != "" {
+			if ok, _ := path.Match(mirror.TagPattern, tag); !ok {
+				continue
+			}
+		}
+
+		record := recorded[tag]
+		if record == nil {
+			record = &models.MirroredTag{MirrorID: mirror.ID, Tag: tag}
+		}
+
+		now := time.Now()
+		digest, tagCopied, err := copier.copyTag(ctx, tag)
+		switch {
+		case err != nil:
+			failed = append(failed, tag)
+			record.Error = err.Error()
+			record.FailedAt = &now
+		default:
+			if tagCopied || record.Digest != digest {
+				record.MirroredAt = &now
+			}
+			if tagCopied {
+				copied++
+			}
+			record.Digest = digest
+			record.Error = ""
+			record.FailedAt = nil
+		}
+
+		if err := s.mirrorRepo.SaveMirroredTag(context.WithoutCancel(ctx), record); err != nil {
+			log.Printf("Failed to record mirrored tag %s of mirror %d: %v", tag, mirror.ID, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return copied, failed, err
+		}
+	}
+
+	log.Printf("Mirror %d copied %d tags from %s to %s, %d failed, %d bytes transferred",
+		mirror.ID, copied, mirror.Upstream, mirror.Target, len(failed), copier.bytes)
+	return copied, failed, nil
+}
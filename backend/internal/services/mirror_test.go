@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	gormrepo "github.com/ofkm/svelocker-ui/backend/internal/repository/gorm"
+)
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		upstream string
+		baseURL  string
+		repoPath string
+	}{
+		{"postgres", "https://registry-1.docker.io", "library/postgres"},
+		{"docker.io/postgres", "https://registry-1.docker.io", "library/postgres"},
+		{"docker.io/library/postgres", "https://registry-1.docker.io", "library/postgres"},
+		{"index.docker.io/bitnami/postgresql", "https://registry-1.docker.io", "bitnami/postgresql"},
+		{"bitnami/postgresql", "https://registry-1.docker.io", "bitnami/postgresql"},
+		{"ghcr.io/org/team/app", "https://ghcr.io", "org/team/app"},
+		{"localhost:5000/x", "https://localhost:5000", "x"},
+		{"localhost/team/x", "https://localhost", "team/x"},
+		{"http://localhost:5000/x", "http://localhost:5000", "x"},
+		{"https://registry.example.com/x/", "https://registry.example.com", "x"},
+	}
+	for _, tt := range tests {
+		baseURL, repoPath, err := parseUpstream(tt.upstream)
+		if err != nil {
+			t.Errorf("parseUpstream(%q) failed: %v", tt.upstream, err)
+			continue
+		}
+		if baseURL != tt.baseURL || repoPath != tt.repoPath {
+			t.Errorf("parseUpstream(%q) = %q, %q; want %q, %q", tt.upstream, baseURL, repoPath, tt.baseURL, tt.repoPath)
+		}
+	}
+
+	for _, upstream := range []string{"", "ghcr.io/Org/App", "localhost:5000/bad name"} {
+		if _, _, err := parseUpstream(upstream); !errors.Is(err, ErrInvalidMirror) {
+			t.Errorf("parseUpstream(%q) = %v, want ErrInvalidMirror", upstream, err)
+		}
+	}
+}
+
+func newTestMirrorService(t *testing.T, local *fakeRegistry) (*MirrorService, *fakeLocal) {
+	t.Helper()
+	db := openTestDB(t, &models.Mirror{}, &models.MirroredTag{})
+	syncer := &fakeLocal{protected: map[string]bool{}}
+	return NewMirrorService(gormrepo.NewMirrorRepository(db), local.client(), syncer), syncer
+}
+
+// mirroredTags returns the mirrored tags of a mirror by name
+func mirroredTags(t *testing.T, svc *MirrorService, id uint) map[string]models.MirroredTag {
+	t.Helper()
+	mirror, err := svc.GetMirror(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get mirror: %v", err)
+	}
+	tags := make(map[string]models.MirroredTag, len(mirror.Tags))
+	for _, tag := range mirror.Tags {
+		tags[tag.Tag] = tag
+	}
+	return tags
+}
+
+func TestRunMirrorCopiesMatchingTags(t *testing.T) {
+	upstream, local := newFakeRegistry(t), newFakeRegistry(t)
+	first := upstream.pushImage("library/postgres", "16.1", `{"tag":"16.1"}`, "base", "16.1")
+	second := upstream.pushImage("library/postgres", "16.2", `{"tag":"16.2"}`, "base", "16.2")
+	upstream.pushImage("library/postgres", "15.0", `{"tag":"15.0"}`, "base", "15.0")
+
+	svc, syncer := newTestMirrorService(t, local)
+	ctx := context.Background()
+	source := upstream.server.URL + "/library/postgres:16*"
+	mirror, err := svc.SaveMirror(ctx, 0, MirrorRequest{Source: source, Target: "mirror/postgres"})
+	if err != nil {
+		t.Fatalf("SaveMirror failed: %v", err)
+	}
+	if mirror.TagPattern != "16*" || mirror.Cron != models.SyncScheduleManual {
+		t.Fatalf("saved pattern %q and cron %q, want 16* and manual", mirror.TagPattern, mirror.Cron)
+	}
+
+	if err := svc.runMirror(ctx, mirror.ID); err != nil {
+		t.Fatalf("runMirror failed: %v", err)
+	}
+	if local.tagDigest("mirror/postgres", "16.1") != first || local.tagDigest("mirror/postgres", "16.2") != second {
+		t.Errorf("matching tags weren't mirrored")
+	}
+	if d := local.tagDigest("mirror/postgres", "15.0"); d != "" {
+		t.Errorf("15.0 doesn't match the pattern but was mirrored as %s", d)
+	}
+
+	tags := mirroredTags(t, svc, mirror.ID)
+	if len(tags) != 2 || tags["16.1"].Digest != first || tags["16.2"].Digest != second {
+		t.Errorf("recorded %+v, want the digests of 16.1 and 16.2", tags)
+	}
+	if synced := syncer.syncedImages(); !slices.Equal(synced, []string{"mirror/postgres"}) {
+		t.Errorf("synced %v after the mirror, want [mirror/postgres]", synced)
+	}
+
+	// Nothing changed upstream, so the next run copies nothing
+	uploads := local.counters().uploads
+	if err := svc.runMirror(ctx, mirror.ID); err != nil {
+		t.Fatalf("second runMirror failed: %v", err)
+	}
+	if n := local.counters().uploads - uploads; n != 0 {
+		t.Errorf("unchanged tags uploaded %d blobs", n)
+	}
+	if again := mirroredTags(t, svc, mirror.ID); !again["16.1"].MirroredAt.Equal(*tags["16.1"].MirroredAt) {
+		t.Errorf("unchanged tag got mirrored again at %v", again["16.1"].MirroredAt)
+	}
+	if synced := syncer.syncedImages(); len(synced) != 1 {
+		t.Errorf("a run without changes synced %v", synced[1:])
+	}
+
+	// Only the tag that moved upstream is copied
+	moved := upstream.pushImage("library/postgres", "16.2", `{"tag":"16.2","rebuilt":true}`, "base", "16.2 rebuilt")
+	if err := svc.runMirror(ctx, mirror.ID); err != nil {
+		t.Fatalf("third runMirror failed: %v", err)
+	}
+	if d := local.tagDigest("mirror/postgres", "16.2"); d != moved {
+		t.Errorf("moved tag points to %s locally, want %s", d, moved)
+	}
+	if n := local.counters().uploads - uploads; n != 2 {
+		t.Errorf("moved tag uploaded %d blobs, want its new config and layer", n)
+	}
+}
+
+func TestRunMirrorRecordsFailuresPerTag(t *testing.T) {
+	upstream, local := newFakeRegistry(t), newFakeRegistry(t)
+	good := upstream.pushImage("team/app", "good", `{"tag":"good"}`, "good layer")
+	upstream.pushImage("team/app", "protected", `{"tag":"protected"}`, "new layer")
+	local.pushImage("mirror/app", "protected", `{"tag":"protected"}`, "old layer")
+
+	// A manifest whose layer the upstream lost can't be copied
+	broken := strings.Replace(string(upstream.manifests["team/app@"+good].body), fakeDigest([]byte("good layer")), fakeDigest([]byte("lost layer")), 1)
+	upstream.pushManifest("team/app", "broken", ociManifestType, []byte(broken))
+
+	svc, syncer := newTestMirrorService(t, local)
+	syncer.protected["protected"] = true
+	ctx := context.Background()
+	source := upstream.server.URL + "/team/app"
+	mirror, err := svc.SaveMirror(ctx, 0, MirrorRequest{Source: source, Target: "mirror/app"})
+	if err != nil {
+		t.Fatalf("SaveMirror failed: %v", err)
+	}
+
+	if err := svc.runMirror(ctx, mirror.ID); err != nil {
+		t.Fatalf("runMirror failed: %v", err)
+	}
+
+	mirror, err = svc.GetMirror(ctx, mirror.ID)
+	if err != nil {
+		t.Fatalf("failed to get mirror: %v", err)
+	}
+	if mirror.LastStatus != models.MirrorStatusPartial || !strings.Contains(mirror.LastError, "2 tags failed") {
+		t.Errorf("mirror ended %s with %q, want partial with 2 failed tags", mirror.LastStatus, mirror.LastError)
+	}
+
+	tags := mirroredTags(t, svc, mirror.ID)
+	if tag := tags["good"]; tag.Digest != good || tag.Error != "" || tag.MirroredAt == nil {
+		t.Errorf("good tag recorded as %+v", tag)
+	}
+	for _, name := range []string{"broken", "protected"} {
+		if tag := tags[name]; tag.Error == "" || tag.FailedAt == nil || tag.MirroredAt != nil {
+			t.Errorf("%s tag recorded as %+v, want its failure", name, tag)
+		}
+	}
+	if !strings.Contains(tags["protected"].Error, "protected") {
+		t.Errorf("protected tag failed with %q", tags["protected"].Error)
+	}
+
+	// Once the tag can be copied its error is cleared
+	delete(syncer.protected, "protected")
+	if err := svc.runMirror(ctx, mirror.ID); err != nil {
+		t.Fatalf("second runMirror failed: %v", err)
+	}
+	if tag := mirroredTags(t, svc, mirror.ID)["protected"]; tag.Error != "" || tag.FailedAt != nil || tag.MirroredAt == nil {
+		t.Errorf("protected tag recorded as %+v after it was copied", tag)
+	}
+}
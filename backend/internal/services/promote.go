@@ -0,0 +1,140 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ErrInvalidPromotion is returned for promotions with an invalid source or
+// target, or a target that is the source itself
+var ErrInvalidPromotion = errors.New("invalid promotion")
+
+// Tag and repository names as the distribution spec allows them
+var (
+	tagNamePattern        = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+	repositoryNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+)
+
+// PromoteRequest copies the manifest a reference points to under another tag,
+// in the same repository or in another one of the registry
+type PromoteRequest struct {
+	Source           string `json:"source" binding:"required"` // "namespace/image:tag" or "namespace/image@sha256:..."
+	TargetRepository string `json:"targetRepository"`          // Registry path, defaults to the source repository
+	TargetTag        string `json:"targetTag"`                 // Defaults to the source tag
+}
+
+// Promotion describes a manifest that was tagged in its target
+type Promotion struct {
+	Source       string      `json:"source"`
+	Target       string      `json:"target"`
+	Digest       string      `json:"digest"`
+	MountedBlobs int         `json:"mountedBlobs"` // Blobs linked from the source repository
+	CopiedBlobs  int         `json:"copiedBlobs"`  // Blobs the registry didn't mount, uploaded instead
+	Sync         *SyncResult `json:"sync,omitempty"`
+}
+
+// Promote tags the manifest of a source reference in a target repository. The
+// raw manifest is pushed unchanged so the digest stays the same; when the
+// repository differs its blobs and platform manifests are mounted from the
+// source first. Overwriting a protected tag is refused with a
+// *ProtectedTagError. The target tag is synced afterwards.
+func (s *SyncService) Promote(ctx context.Context, req PromoteRequest) (*Promotion, error) {
+	sourcePath, reference, byDigest, err := parseImageReference(req.Source)
+	if err != nil {
+		return nil, err
+	}
+	namespace, imageName := utils.SplitRepositoryPath(sourcePath)
+	if sourcePath, err = s.repositoryPath(ctx, namespace, imageName); err != nil {
+		return nil, err
+	}
+
+	targetPath := strings.Trim(cmp.Or(req.TargetRepository, sourcePath), "/")
+	if !repositoryNamePattern.MatchString(targetPath) {
+		return nil, fmt.Errorf("%w: %q is not a valid repository", ErrInvalidPromotion, targetPath)
+	}
+	targetTag := req.TargetTag
+	if targetTag == "" {
+		if byDigest {
+			return nil, fmt.Errorf("%w: a target tag is required when promoting a digest", ErrInvalidPromotion)
+		}
+		targetTag = reference
+	}
+	if !tagNamePattern.MatchString(targetTag) {
+		return nil, fmt.Errorf("%w: %q is not a valid tag", ErrInvalidPromotion, targetTag)
+	}
+	if targetPath == sourcePath && targetTag == reference {
+		return nil, fmt.Errorf("%w: the target is the source itself", ErrInvalidPromotion)
+	}
+
+	targetNamespace, targetImage := utils.SplitRepositoryPath(targetPath)
+	if err := s.checkTagProtection(ctx, targetNamespace, targetImage, []string{targetTag}); err != nil {
+		return nil, err
+	}
+
+	manifest, err := s.registry.GetManifest(ctx, sourcePath, reference)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%s: %w", req.Source, ErrNotInRegistry)
+		}
+		return nil, fmt.Errorf("failed to get manifest of %s: %w", req.Source, err)
+	}
+
+	copier := &manifestCopier{
+		source:     s.registry,
+		target:     s.registry,
+		sourcePath: sourcePath,
+		targetPath: targetPath,
+		mount:      true,
+	}
+	if targetPath != sourcePath {
+		if err := copier.copyReferences(ctx, manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	digest, err := s.registry.PutManifest(ctx, targetPath, targetTag, manifest.MediaType, manifest.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to push manifest to %s:%s: %w", targetPath, targetTag, err)
+	}
+
+	promotion := &Promotion{
+		Source:       req.Source,
+		Target:       targetPath + ":" + targetTag,
+		Digest:       cmp.Or(digest, manifest.Digest),
+		MountedBlobs: copier.mounted,
+		CopiedBlobs:  copier.copied,
+	}
+	log.Printf("Promoted %s to %s (%s)", promotion.Source, promotion.Target, promotion.Digest)
+
+	// The registry has the tag now, a failed sync only leaves the UI behind
+	promotion.Sync, err = s.SyncTag(ctx, models.SyncTriggerPromote, targetNamespace, targetImage, targetTag)
+	if err != nil {
+		log.Printf("Failed to sync promoted tag %s: %v", promotion.Target, err)
+	}
+	return promotion, nil
+}
+
+// parseImageReference splits "repository:tag" or "repository@digest"
+func parseImageReference(ref string) (repoPath, reference string, byDigest bool, err error) {
+	if repoPath, digest, ok := strings.Cut(ref, "@"); ok {
+		if !strings.Contains(digest, ":") || repoPath == "" {
+			return "", "", false, fmt.Errorf("%w: %q is not a valid digest reference", ErrInvalidPromotion, ref)
+		}
+		return strings.Trim(repoPath, "/"), digest, true, nil
+	}
+
+	// The tag follows the last colon, as long as no slash comes after it
+	i := strings.LastIndex(ref, ":")
+	if i <= 0 || strings.Contains(ref[i:], "/") || i == len(ref)-1 {
+		return "", "", false, fmt.Errorf("%w: %q is not a namespace/image:tag reference", ErrInvalidPromotion, ref)
+	}
+	return strings.Trim(ref[:i], "/"), ref[i+1:], false, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default token lifetime when the token server does not send expires_in,
+// as defined by the distribution token authentication spec
+const defaultTokenLifetime = 60 * time.Second
+
+// Tokens are refreshed a little before they actually expire
+const tokenExpiryLeeway = 5 * time.Second
+
+// bearerChallenge is a parsed WWW-Authenticate: Bearer challenge
+type bearerChallenge struct {
+	Realm   string
+	Service string
+	Scope   string
+}
+
+type bearerToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (t *bearerToken) valid() bool {
+	return t != nil && t.value != "" && time.Now().Add(tokenExpiryLeeway).Before(t.expiresAt)
+}
+
+// tokenCache stores bearer tokens per challenge scope
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*bearerToken
+	// scopes maps the scope we derive from a request to the scope the
+	// registry actually challenged with, so later requests can reuse the token
+	scopes map[string]string
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		tokens: make(map[string]*bearerToken),
+		scopes: make(map[string]string),
+	}
+}
+
+func (tc *tokenCache) lookup(requestScope string) string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	scope, ok := tc.scopes[requestScope]
+	if !ok {
+		return ""
+	}
+	token := tc.tokens[scope]
+	if !token.valid() {
+		delete(tc.tokens, scope)
+		return ""
+	}
+	return token.value
+}
+
+func (tc *tokenCache) store(requestScope string, challenge *bearerChallenge, token *bearerToken) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	key := challenge.cacheKey()
+	tc.tokens[key] = token
+	tc.scopes[requestScope] = key
+}
+
+func (ch *bearerChallenge) cacheKey() string {
+	return ch.Realm + "|" + ch.Service + "|" + ch.Scope
+}
+
+// parseBearerChallenge parses a WWW-Authenticate header value and returns the
+// Bearer challenge, or nil if the registry asked for another scheme
+func parseBearerChallenge(header string) *bearerChallenge {
+	header = strings.TrimSpace(header)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return nil
+	}
+
+	params := parseAuthParams(header[7:])
+	if params["realm"] == "" {
+		return nil
+	}
+
+	return &bearerChallenge{
+		Realm:   params["realm"],
+		Service: params["service"],
+		Scope:   params["scope"],
+	}
+}
+
+// parseAuthParams splits a comma separated list of key=value pairs where
+// values may be quoted and contain commas (e.g. scope="repository:a:pull,push")
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			s = s[1:]
+			var b strings.Builder
+			for len(s) > 0 && s[0] != '"' {
+				if s[0] == '\\' && len(s) > 1 {
+					s = s[1:]
+				}
+				b.WriteByte(s[0])
+				s = s[1:]
+			}
+			s = strings.TrimPrefix(s, `"`)
+			value = b.String()
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params
+}
+
+// requestScope derives the token scope a registry is expected to ask for
+// when serving the given request. It is only used as a cache key.
+func requestScope(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "_catalog" {
+		return "registry:catalog:*"
+	}
+
+	// Repository names may contain slashes, so cut at the API resource
+	name := path
+	for _, marker := range []string{"/manifests/", "/blobs/", "/tags/", "/referrers/"} {
+		if idx := strings.LastIndex(path, marker); idx >= 0 {
+			name = path[:idx]
+			break
+		}
+	}
+
+	action := "pull"
+	switch req.Method {
+	case http.MethodDelete:
+		action = "delete"
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+		action = "pull,push"
+	}
+
+	return fmt.Sprintf("repository:%s:%s", name, action)
+}
+
+// fetchToken requests a bearer token from the realm of the challenge
+func (c *RegistryClient) fetchToken(ctx context.Context, challenge *bearerChallenge) (*bearerToken, error) {
+	realm, err := url.Parse(challenge.Realm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token realm %q: %w", challenge.Realm, err)
+	}
+
+	query := realm.Query()
+	if challenge.Service != "" {
+		query.Set("service", challenge.Service)
+	}
+	if challenge.Scope != "" {
+		for _, scope := range strings.Split(challenge.Scope, " ") {
+			query.Add("scope", scope)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", registryUserAgent)
+	if c.username != "" && c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("token server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Token       string    `json:"token"`
+		AccessToken string    `json:"access_token"`
+		ExpiresIn   int       `json:"expires_in"`
+		IssuedAt    time.Time `json:"issued_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	token := result.Token
+	if token == "" {
+		token = result.AccessToken
+	}
+	if token == "" {
+		return nil, fmt.Errorf("token server returned an empty token")
+	}
+
+	lifetime := defaultTokenLifetime
+	if result.ExpiresIn > 0 {
+		lifetime = time.Duration(result.ExpiresIn) * time.Second
+	}
+	issuedAt := time.Now()
+	if !result.IssuedAt.IsZero() && result.IssuedAt.Before(issuedAt) {
+		issuedAt = result.IssuedAt
+	}
+
+	return &bearerToken{value: token, expiresAt: issuedAt.Add(lifetime)}, nil
+}
+
+// do sends a request to the registry with the right credentials. Cached
+// bearer tokens are attached up front, otherwise basic auth is used. When the
+// registry answers 401 with a Bearer challenge, a token is fetched from the
+// realm and the request is retried once.
+func (c *RegistryClient) do(req *http.Request) (*http.Response, error) {
+	scope := requestScope(req)
+	req.Header.Set("User-Agent", registryUserAgent)
+
+	if token := c.tokens.lookup(scope); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.username != "" && c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := parseBearerChallenge(resp.Header.Get("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	token, err := c.fetchToken(req.Context(), challenge)
+	if err != nil {
+		return nil, fmt.Errorf("registry authentication failed: %w", err)
+	}
+	c.tokens.store(scope, challenge, token)
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("cannot retry %s %s after authentication: request body is not replayable", req.Method, req.URL.Path)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("Authorization", "Bearer "+token.value)
+
+	return c.client.Do(retry)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenRegistry is a registry that only accepts bearer tokens from its token
+// server, which hands them out for basic auth credentials
+type tokenRegistry struct {
+	mu            sync.Mutex
+	registry      *httptest.Server
+	tokenServer   *httptest.Server
+	expiresIn     int               // expires_in of issued tokens, 0 leaves it out
+	tokens        map[string]string // Issued token -> scope
+	tokenRequests []string          // Scopes tokens were requested for
+	requests      []string          // Requests as "METHOD path auth-scheme"
+	bodies        []string          // Bodies of authorized requests
+}
+
+func newTokenRegistry(t *testing.T) *tokenRegistry {
+	t.Helper()
+	r := &tokenRegistry{tokens: make(map[string]string)}
+	r.tokenServer = httptest.NewServer(http.HandlerFunc(r.serveToken))
+	r.registry = httptest.NewServer(http.HandlerFunc(r.serveRegistry))
+	t.Cleanup(r.registry.Close)
+	t.Cleanup(r.tokenServer.Close)
+	return r
+}
+
+func (r *tokenRegistry) serveToken(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, password, ok := req.BasicAuth(); !ok || user != "user" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Query().Get("service") != "registry.test" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	scope := req.URL.Query().Get("scope")
+	r.tokenRequests = append(r.tokenRequests, scope)
+	token := fmt.Sprintf("token-%d", len(r.tokenRequests))
+	r.tokens[token] = scope
+
+	response := map[string]any{"token": token}
+	if r.expiresIn > 0 {
+		response["expires_in"] = r.expiresIn
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (r *tokenRegistry) serveRegistry(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repository, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/"), "/manifests/")
+	scope := "repository:" + repository + ":pull"
+	if req.Method == http.MethodPut {
+		scope = "repository:" + repository + ":pull,push"
+	}
+
+	scheme, token, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path+" "+scheme)
+	if scheme != "Bearer" || r.tokens[token] != scope {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test",scope="%s"`, r.tokenServer.URL, scope))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(body))
+	if req.Method == http.MethodPut {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	io.WriteString(w, `{"schemaVersion":2}`)
+}
+
+func (r *tokenRegistry) client() *RegistryClient {
+	return NewRegistryClient(r.registry.URL, "user", "secret")
+}
+
+// counts returns how many tokens and registry requests were served so far
+func (r *tokenRegistry) counts() (tokenRequests, requests int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.tokenRequests), len(r.requests)
+}
+
+func TestDoRetriesWithTokenAfterBearerChallenge(t *testing.T) {
+	r := newTokenRegistry(t)
+	client := r.client()
+
+	if _, err := client.GetManifest(context.Background(), "team/app", "latest"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+
+	wantRequests := []string{"GET /v2/team/app/manifests/latest Basic", "GET /v2/team/app/manifests/latest Bearer"}
+	if strings.Join(r.requests, "\n") != strings.Join(wantRequests, "\n") {
+		t.Errorf("registry got %q, want %q", r.requests, wantRequests)
+	}
+	if len(r.tokenRequests) != 1 || r.tokenRequests[0] != "repository:team/app:pull" {
+		t.Errorf("requested tokens for %q, want one for repository:team/app:pull", r.tokenRequests)
+	}
+}
+
+func TestDoReusesTokensPerScope(t *testing.T) {
+	r := newTokenRegistry(t)
+	client := r.client()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetManifest(ctx, "team/app", "latest"); err != nil {
+			t.Fatalf("GetManifest failed: %v", err)
+		}
+	}
+	// The cached token is sent up front, only the first request is challenged
+	if tokens, requests := r.counts(); tokens != 1 || requests != 4 {
+		t.Errorf("%d token requests and %d registry requests, want 1 and 4", tokens, requests)
+	}
+
+	// Another repository has another scope and needs its own token
+	if _, err := client.GetManifest(ctx, "team/other", "latest"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if _, err := client.GetManifest(ctx, "team/app", "latest"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if tokens, requests := r.counts(); tokens != 2 || requests != 7 {
+		t.Errorf("%d token requests and %d registry requests, want 2 and 7", tokens, requests)
+	}
+}
+
+func TestDoRefreshesExpiredTokens(t *testing.T) {
+	r := newTokenRegistry(t)
+	// Tokens this short-lived are within the expiry leeway right away
+	r.expiresIn = 1
+	client := r.client()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetManifest(context.Background(), "team/app", "latest"); err != nil {
+			t.Fatalf("GetManifest failed: %v", err)
+		}
+	}
+	if tokens, requests := r.counts(); tokens != 2 || requests != 4 {
+		t.Errorf("%d token requests and %d registry requests, want 2 and 4", tokens, requests)
+	}
+
+	token := &bearerToken{value: "token", expiresAt: time.Now().Add(defaultTokenLifetime)}
+	if !token.valid() {
+		t.Errorf("token with the default lifetime isn't valid")
+	}
+	token.expiresAt = time.Now().Add(tokenExpiryLeeway / 2)
+	if token.valid() {
+		t.Errorf("token expiring within the leeway is valid")
+	}
+}
+
+func TestDoReplaysRequestBodies(t *testing.T) {
+	r := newTokenRegistry(t)
+	client := r.client()
+
+	// Requests with a bytes body can be sent again after the challenge
+	manifest := []byte(`{"schemaVersion":2}`)
+	req, err := http.NewRequest(http.MethodPut, r.registry.URL+"/v2/team/app/manifests/latest", bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("registry answered %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if len(r.bodies) != 1 || r.bodies[0] != string(manifest) {
+		t.Errorf("registry received %q, want the manifest once", r.bodies)
+	}
+	if len(r.tokenRequests) != 1 || r.tokenRequests[0] != "repository:team/app:pull,push" {
+		t.Errorf("requested tokens for %q, want one for repository:team/app:pull,push", r.tokenRequests)
+	}
+}
+
+func TestDoFailsForBodiesThatCantBeReplayed(t *testing.T) {
+	r := newTokenRegistry(t)
+	client := r.client()
+
+	// Streamed bodies have no GetBody, they can only be sent once
+	body := io.NopCloser(strings.NewReader(`{"schemaVersion":2}`))
+	req, err := http.NewRequest(http.MethodPut, r.registry.URL+"/v2/team/app/manifests/latest", body)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	resp, err := client.do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("do succeeded, want an error for the body")
+	}
+	if !strings.Contains(err.Error(), "not replayable") {
+		t.Errorf("do failed with %v, want a request body that is not replayable", err)
+	}
+	if len(r.bodies) != 0 {
+		t.Errorf("registry accepted %q", r.bodies)
+	}
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	challenge := parseBearerChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:team/app:pull,push"`)
+	want := bearerChallenge{Realm: "https://auth.example.com/token", Service: "registry.example.com", Scope: "repository:team/app:pull,push"}
+	if challenge == nil || *challenge != want {
+		t.Errorf("parseBearerChallenge = %+v, want %+v", challenge, want)
+	}
+
+	for _, header := range []string{"", `Basic realm="registry"`, `Bearer service="registry.example.com"`} {
+		if challenge := parseBearerChallenge(header); challenge != nil {
+			t.Errorf("parseBearerChallenge(%q) = %+v, want nil", header, challenge)
+		}
+	}
+}
@@ -1,23 +1,64 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
+// Number of entries requested per catalog or tag list page
+const catalogPageSize = 100
+
+// Accept header for manifest requests, preferring concrete manifests over lists
+const manifestAcceptHeader = "application/vnd.docker.distribution.manifest.v2+json," +
+	"application/vnd.oci.image.manifest.v1+json," +
+	"application/vnd.docker.distribution.manifest.list.v2+json," +
+	"application/vnd.oci.image.index.v1+json"
+
+// User-Agent of all registry requests, so our own pulls can be told apart in
+// registry notifications
+const registryUserAgent = "svelocker-ui"
+
+// ErrDeleteUnsupported is returned when the registry has deletes disabled,
+// distribution only allows them with storage.delete.enabled set
+var ErrDeleteUnsupported = errors.New("the registry does not allow deleting manifests, enable storage.delete in its configuration")
+
+// RegistryStatusError is returned when the registry answers with an
+// unexpected status code
+type RegistryStatusError struct {
+	StatusCode int
+	Message    string // Response body, if the registry sent one
+}
+
+func (e *RegistryStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("registry returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("registry returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// isNotFound reports whether the registry answered a request with 404 Not Found
+func isNotFound(err error) bool {
+	var statusErr *RegistryStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
 type RegistryClient struct {
 	baseURL  string
 	username string
 	password string
 	client   *http.Client
+	tokens   *tokenCache
 }
 
 type RegistryCatalog struct {
@@ -25,6 +66,10 @@ type RegistryCatalog struct {
 }
 
 type ManifestResponse struct {
+	// Digest is taken from the Docker-Content-Digest response header
+	Digest string `json:"-"`
+	// Raw is the manifest as the registry returned it, the digest is computed over these bytes
+	Raw           []byte `json:"-"`
 	SchemaVersion int    `json:"schemaVersion"`
 	MediaType     string `json:"mediaType"`
 	Config        struct {
@@ -38,18 +83,36 @@ type ManifestResponse struct {
 		Size      int64  `json:"size"`
 	} `json:"layers"`
 	Manifests []struct {
-		MediaType string `json:"mediaType"`
-		Digest    string `json:"digest"`
-		Size      int64  `json:"size"`
-		Platform  struct {
-			Architecture string `json:"architecture"`
-			OS           string `json:"os"`
-		} `json:"platform"`
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Platform    ManifestPlatform  `json:"platform"`
+		Annotations map[string]string `json:"annotations,omitempty"`
 	} `json:"manifests,omitempty"`
-	Platform struct {
-		Architecture string `json:"architecture"`
-		OS           string `json:"os"`
-	} `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ManifestPlatform is the platform of a manifest in an index
+type ManifestPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	OSVersion    string `json:"os.version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// IsOCI reports whether the manifest uses the OCI media types rather than
+// Docker's. OCI manifests may leave out their media type, the config's tells then.
+func (m *ManifestResponse) IsOCI() bool {
+	if m.MediaType != "" {
+		return strings.HasPrefix(m.MediaType, "application/vnd.oci.")
+	}
+	return strings.HasPrefix(m.Config.MediaType, "application/vnd.oci.")
+}
+
+// IsIndex reports whether the manifest is an OCI index or Docker manifest list
+func (m *ManifestResponse) IsIndex() bool {
+	return m.MediaType == "application/vnd.oci.image.index.v1+json" ||
+		m.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
 }
 
 type ConfigResponse struct {
@@ -57,12 +120,28 @@ type ConfigResponse struct {
 	Created      string `json:"created"`
 	Author       string `json:"author"`
 	OS           string `json:"os"`
+	OSVersion    string `json:"os.version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
 	Config       struct {
 		Labels       map[string]string   `json:"Labels"`
 		WorkingDir   string              `json:"WorkingDir"`
 		Cmd          []string            `json:"Cmd"`
 		Entrypoint   []string            `json:"Entrypoint"`
 		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Env          []string            `json:"Env"`
+		User         string              `json:"User"`
+		Volumes      map[string]struct{} `json:"Volumes"`
+		StopSignal   string              `json:"StopSignal"`
+		Shell        []string            `json:"Shell"`
+		OnBuild      []string            `json:"OnBuild"`
+		Healthcheck  *struct {
+			Test          []string      `json:"Test"`
+			Interval      time.Duration `json:"Interval"`
+			Timeout       time.Duration `json:"Timeout"`
+			StartPeriod   time.Duration `json:"StartPeriod"`
+			StartInterval time.Duration `json:"StartInterval"`
+			Retries       int           `json:"Retries"`
+		} `json:"Healthcheck"`
 	} `json:"config"`
 	History []struct {
 		Created    string `json:"created"`
@@ -87,6 +166,7 @@ func NewRegistryClient(baseURL, username, password string) *RegistryClient {
 		username: username,
 		password: password,
 		client:   client,
+		tokens:   newTokenCache(),
 	}
 }
 
@@ -106,80 +186,160 @@ func GetRegistryClient() *RegistryClient {
 	return registryClientInstance
 }
 
+// ListRepositories returns every repository in the registry catalog
 func (c *RegistryClient) ListRepositories(ctx context.Context) ([]string, error) {
-	url := fmt.Sprintf("%s/v2/_catalog", c.baseURL)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	var repositories []string
+	err := c.WalkRepositories(ctx, func(page []string) error {
+		repositories = append(repositories, page...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return repositories, nil
+}
 
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
+// WalkRepositories reads the registry catalog page by page, following the
+// Link header, and calls fn for every page. Returning an error from fn stops the walk.
+func (c *RegistryClient) WalkRepositories(ctx context.Context, fn func(page []string) error) error {
+	url := fmt.Sprintf("%s/v2/_catalog?n=%d", c.baseURL, catalogPageSize)
+
+	return c.walkPages(ctx, url, func(resp *http.Response) ([]string, error) {
+		var catalog RegistryCatalog
+		if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+			return nil, err
+		}
+		return catalog.Repositories, nil
+	}, fn)
+}
 
-	resp, err := c.client.Do(req)
+// ListTags returns every tag of a repository
+func (c *RegistryClient) ListTags(ctx context.Context, repository string) ([]string, error) {
+	tags := []string{}
+	err := c.WalkTags(ctx, repository, func(page []string) error {
+		tags = append(tags, page...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
-	}
-
-	var catalog RegistryCatalog
-	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
-		return nil, err
+	if len(tags) == 0 {
+		log.Printf("Repository %s exists but has no tags", repository)
 	}
 
-	return catalog.Repositories, nil
+	return tags, nil
 }
 
-// Update ListTags to provide better error reporting and handle path correctly
-func (c *RegistryClient) ListTags(ctx context.Context, repository string) ([]string, error) {
+// WalkTags reads the tag list of a repository page by page, following the
+// Link header, and calls fn for every page. Returning an error from fn stops the walk.
+func (c *RegistryClient) WalkTags(ctx context.Context, repository string, fn func(page []string) error) error {
 	// Make sure repository is correctly formatted (no leading or trailing slashes)
 	repository = strings.Trim(repository, "/")
 
-	url := fmt.Sprintf("%s/v2/%s/tags/list", c.baseURL, repository)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	url := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", c.baseURL, repository, catalogPageSize)
 
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
+	return c.walkPages(ctx, url, func(resp *http.Response) ([]string, error) {
+		var result struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return result.Tags, nil
+	}, fn)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// walkPages requests pageURL, hands the decoded entries to fn and moves on to
+// the next page until the registry stops advertising one
+func (c *RegistryClient) walkPages(
+	ctx context.Context,
+	pageURL string,
+	decode func(resp *http.Response) ([]string, error),
+	fn func(page []string) error,
+) error {
+	seen := make(map[string]bool)
+
+	for pageURL != "" {
+		if seen[pageURL] {
+			return fmt.Errorf("registry pagination loops back to %s", pageURL)
+		}
+		seen[pageURL] = true
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Registry error for %s: status=%d, body=%s", url, resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("registry returned status %d for %s: %s",
-			resp.StatusCode, url, string(bodyBytes))
-	}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
 
-	var result struct {
-		Name string   `json:"name"`
-		Tags []string `json:"tags"`
-	}
+		resp, err := c.do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			log.Printf("Registry error for %s: status=%d, body=%s", pageURL, resp.StatusCode, string(bodyBytes))
+			return fmt.Errorf("%s: %w", pageURL, &RegistryStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)})
+		}
+
+		page, err := decode(resp)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		next, err := nextPageURL(req.URL, resp.Header.Get("Link"))
+		if err != nil {
+			return err
+		}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		pageURL = next
 	}
 
-	// If no tags field or it's null, return empty slice rather than nil
-	if result.Tags == nil {
-		log.Printf("Repository %s exists but has no tags", repository)
-		return []string{}, nil
+	return nil
+}
+
+// nextPageURL extracts the rel="next" target from a Link header and resolves
+// it against the URL of the current page. It returns "" on the last page.
+func nextPageURL(current *neturl.URL, linkHeader string) (string, error) {
+	for _, link := range strings.Split(linkHeader, ",") {
+		link = strings.TrimSpace(link)
+		if !strings.HasPrefix(link, "<") {
+			continue
+		}
+		end := strings.Index(link, ">")
+		if end < 0 {
+			continue
+		}
+
+		isNext := false
+		for _, param := range strings.Split(link[end+1:], ";") {
+			param = strings.TrimSpace(param)
+			if strings.EqualFold(param, `rel="next"`) || strings.EqualFold(param, "rel=next") {
+				isNext = true
+				break
+			}
+		}
+		if !isNext {
+			continue
+		}
+
+		target, err := neturl.Parse(link[1:end])
+		if err != nil {
+			return "", fmt.Errorf("invalid Link header %q: %w", linkHeader, err)
+		}
+		return current.ResolveReference(target).String(), nil
 	}
 
-	return result.Tags, nil
+	return "", nil
 }
 
 // Similarly update GetManifest and GetConfig with better error handling
@@ -193,17 +353,9 @@ func (c *RegistryClient) GetManifest(ctx context.Context, repository, reference
 	}
 
 	// Change the order of Accept headers to prefer concrete manifests over lists
-	req.Header.Add("Accept",
-		"application/vnd.docker.distribution.manifest.v2+json,"+
-			"application/vnd.oci.image.manifest.v1+json,"+
-			"application/vnd.docker.distribution.manifest.list.v2+json,"+
-			"application/vnd.oci.image.index.v1+json")
-
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
+	req.Header.Add("Accept", manifestAcceptHeader)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -217,27 +369,50 @@ func (c *RegistryClient) GetManifest(ctx context.Context, repository, reference
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("Error getting manifest for %s:%s - Status: %d, Body: %s",
 			repository, reference, resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
+		return nil, &RegistryStatusError{StatusCode: resp.StatusCode}
 	}
 
 	var manifest ManifestResponse
 	if err := json.Unmarshal(bodyBytes, &manifest); err != nil {
 		return nil, fmt.Errorf("failed to decode manifest: %w", err)
 	}
+	manifest.Digest = resp.Header.Get("Docker-Content-Digest")
+	manifest.Raw = bodyBytes
 
-	// Add explicit platform handling for OCI manifests
-	if manifest.MediaType == "application/vnd.oci.image.index.v1+json" {
-		for _, m := range manifest.Manifests {
-			if m.Platform.OS != "unknown" && m.Platform.Architecture != "unknown" {
-				// Get the actual platform-specific manifest
-				return c.GetManifest(ctx, repository, m.Digest)
-			}
-		}
+	// The media type field is optional in OCI manifests
+	if manifest.MediaType == "" {
+		mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+		manifest.MediaType = strings.TrimSpace(mediaType)
 	}
 
 	return &manifest, nil
 }
 
+// HeadManifest resolves a reference to its manifest digest without
+// downloading the manifest. For multi-arch tags this is the index digest.
+func (c *RegistryClient) HeadManifest(ctx context.Context, repository, reference string) (string, error) {
+	repository = strings.Trim(repository, "/")
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Accept", manifestAcceptHeader)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &RegistryStatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
 func (c *RegistryClient) GetConfig(ctx context.Context, repository, digest string) (*ConfigResponse, error) {
 	url := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -245,18 +420,14 @@ func (c *RegistryClient) GetConfig(ctx context.Context, repository, digest strin
 		return nil, err
 	}
 
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
+		return nil, &RegistryStatusError{StatusCode: resp.StatusCode}
 	}
 
 	var config ConfigResponse
@@ -267,7 +438,193 @@ func (c *RegistryClient) GetConfig(ctx context.Context, repository, digest strin
 	return &config, nil
 }
 
-// DeleteManifest deletes a manifest from the registry using the provided digest
+// PutManifest pushes a manifest under a tag or digest and returns the digest
+// the registry stored it as
+func (c *RegistryClient) PutManifest(ctx context.Context, repository, reference, mediaType string, manifest []byte) (string, error) {
+	repository = strings.Trim(repository, "/")
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(manifest))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &RegistryStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// BlobExists checks with a HEAD request whether a repository has a blob
+func (c *RegistryClient) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	repository = strings.Trim(repository, "/")
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, &RegistryStatusError{StatusCode: resp.StatusCode}
+	}
+}
+
+// GetBlob streams a blob from a repository. The caller closes the body.
+func (c *RegistryClient) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error) {
+	repository = strings.Trim(repository, "/")
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, digest)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, &RegistryStatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// MountBlob asks the registry to link a blob of another repository into
+// repository without uploading it again. It reports false if the registry
+// didn't mount it, the blob has to be uploaded then.
+func (c *RegistryClient) MountBlob(ctx context.Context, repository, digest, from string) (bool, error) {
+	repository = strings.Trim(repository, "/")
+	query := neturl.Values{"mount": {digest}, "from": {strings.Trim(from, "/")}}
+	url := fmt.Sprintf("%s/v2/%s/blobs/uploads/?%s", c.baseURL, repository, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// The registry opened an upload session instead, which isn't needed
+		c.cancelUpload(ctx, resp)
+		return false, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return false, &RegistryStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+}
+
+// UploadBlob pushes a blob to a repository in a single request, streaming
+// size bytes from content. The registry checks them against digest.
+func (c *RegistryClient) UploadBlob(ctx context.Context, repository, digest string, size int64, content io.Reader) error {
+	repository = strings.Trim(repository, "/")
+	url := fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.baseURL, repository)
+
+	// Starting the session first also takes care of authentication, the
+	// streamed body couldn't be sent a second time
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to start upload: %w", &RegistryStatusError{StatusCode: resp.StatusCode})
+	}
+
+	location, err := uploadLocation(resp)
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, location.String(), content)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err = c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return &RegistryStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// uploadLocation returns the URL of the upload session a response opened
+func uploadLocation(resp *http.Response) (*neturl.URL, error) {
+	location, err := resp.Location()
+	if err != nil {
+		return nil, fmt.Errorf("registry didn't return an upload location: %w", err)
+	}
+	return location, nil
+}
+
+// cancelUpload aborts an upload session, failures are only logged as the
+// registry expires sessions on its own
+func (c *RegistryClient) cancelUpload(ctx context.Context, resp *http.Response) {
+	location, err := uploadLocation(resp)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, location.String(), nil)
+	if err != nil {
+		return
+	}
+	cancelResp, err := c.do(req)
+	if err != nil {
+		log.Printf("Failed to cancel upload %s: %v", location.Path, err)
+		return
+	}
+	cancelResp.Body.Close()
+}
+
+// DeleteManifest deletes a manifest from the registry by digest, which also
+// removes every tag that points to it. A manifest that is already gone counts
+// as deleted. Registries with deletes disabled get ErrDeleteUnsupported.
 func (c *RegistryClient) DeleteManifest(ctx context.Context, repository, digest string) error {
 	// Make sure repository is correctly formatted
 	repository = strings.Trim(repository, "/")
@@ -287,63 +644,47 @@ func (c *RegistryClient) DeleteManifest(ctx context.Context, repository, digest
 	}
 
 	// Add ALL relevant accept headers for both OCI and Docker manifests
-	req.Header.Add("Accept",
-		"application/vnd.docker.distribution.manifest.v2+json,"+
-			"application/vnd.oci.image.manifest.v1+json,"+
-			"application/vnd.docker.distribution.manifest.list.v2+json,"+
-			"application/vnd.oci.image.index.v1+json")
+	req.Header.Add("Accept", manifestAcceptHeader)
 
-	// Add authentication
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-
-	// Execute request with retry logic
-	var resp *http.Response
-	maxRetries := 3
+	sanitizedRepository := strings.ReplaceAll(repository, "\n", "")
+	sanitizedRepository = strings.ReplaceAll(sanitizedRepository, "\r", "")
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		sanitizedURL := strings.ReplaceAll(url, "\n", "")
-		sanitizedURL = strings.ReplaceAll(sanitizedURL, "\r", "")
-		log.Printf("DELETE request attempt %d to %s", attempt, sanitizedURL)
-		resp, err = c.client.Do(req)
+	// Only network errors and server errors are worth retrying
+	const maxRetries = 3
+	for attempt := 1; ; attempt++ {
+		log.Printf("DELETE request attempt %d for manifest %s in %s", attempt, digest, sanitizedRepository)
 
+		resp, err := c.do(req)
 		if err == nil {
-			statusOK := resp.StatusCode == http.StatusOK ||
-				resp.StatusCode == http.StatusAccepted
-
-			if statusOK {
-				defer resp.Body.Close()
-				sanitizedRepository := strings.ReplaceAll(repository, "\n", "")
-				sanitizedRepository = strings.ReplaceAll(sanitizedRepository, "\r", "")
-				log.Printf("Successfully deleted manifest %s from %s", digest, sanitizedRepository)
-				return nil
-			}
-		}
-
-		if resp != nil {
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			log.Printf("Attempt %d failed with status %d: %s", attempt, resp.StatusCode, string(body))
 
-			// If NotFound, consider this a success (already deleted)
-			if resp.StatusCode == http.StatusNotFound {
-				sanitizedRepository := strings.ReplaceAll(repository, "\n", "")
-				sanitizedRepository = strings.ReplaceAll(sanitizedRepository, "\r", "")
+			switch {
+			case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted:
+				log.Printf("Successfully deleted manifest %s from %s", digest, sanitizedRepository)
+				return nil
+			case resp.StatusCode == http.StatusNotFound:
 				log.Printf("Manifest %s not found in %s (already deleted)", digest, sanitizedRepository)
 				return nil
+			case resp.StatusCode == http.StatusMethodNotAllowed || bytes.Contains(body, []byte("UNSUPPORTED")):
+				return ErrDeleteUnsupported
 			}
 
-			// Wait before retrying
-			if attempt < maxRetries {
-				time.Sleep(time.Duration(attempt) * time.Second)
+			err = &RegistryStatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+			if resp.StatusCode < http.StatusInternalServerError {
+				return err
 			}
 		}
-	}
 
-	if resp != nil {
-		return fmt.Errorf("registry returned status %d after %d attempts", resp.StatusCode, maxRetries)
-	}
+		if attempt == maxRetries {
+			return fmt.Errorf("failed to delete manifest after %d attempts: %w", maxRetries, err)
+		}
+		log.Printf("Attempt %d failed: %v", attempt, err)
 
-	return fmt.Errorf("failed to delete manifest after %d attempts: %w", maxRetries, err)
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNextPageURL(t *testing.T) {
+	current, _ := neturl.Parse("https://registry.example.com/v2/_catalog?n=2")
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{`</v2/_catalog?n=2&last=b>; rel="next"`, "https://registry.example.com/v2/_catalog?n=2&last=b"},
+		{`<?n=2&last=b>; rel=next`, "https://registry.example.com/v2/_catalog?n=2&last=b"},
+		{`<https://mirror.example.com/v2/_catalog?last=b>; rel="next"`, "https://mirror.example.com/v2/_catalog?last=b"},
+		{`</v2/_catalog?last=a>; rel="prev", </v2/_catalog?last=c>; rel="next"`, "https://registry.example.com/v2/_catalog?last=c"},
+		{`</v2/_catalog?last=a>; rel="prev"`, ""},
+	}
+	for _, tt := range tests {
+		got, err := nextPageURL(current, tt.link)
+		if err != nil {
+			t.Errorf("nextPageURL(%q) failed: %v", tt.link, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+// pagedRegistry serves the catalog and the tags of team/app in pages. links
+// maps the last entry of a page to the Link header sent with it.
+func pagedRegistry(t *testing.T, entries []string, links map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := 0
+		if last := r.URL.Query().Get("last"); last != "" {
+			start = slices.Index(entries, last) + 1
+		}
+		end := min(start+2, len(entries))
+		page := entries[start:end]
+
+		if link := links[page[len(page)-1]]; link != "" {
+			w.Header().Set("Link", link)
+		}
+		if r.URL.Path == "/v2/_catalog" {
+			json.NewEncoder(w).Encode(map[string]any{"repositories": page})
+		} else {
+			json.NewEncoder(w).Encode(map[string]any{"name": "team/app", "tags": page})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWalkPagesFollowsLinks(t *testing.T) {
+	entries := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name  string
+		links map[string]string
+	}{
+		{"absolute path", map[string]string{
+			"b": `</v2/_catalog?n=2&last=b>; rel="next"`,
+			"d": `</v2/_catalog?n=2&last=d>; rel="next"`,
+		}},
+		{"relative query", map[string]string{
+			"b": `<?n=2&last=b>; rel="next"`,
+			"d": `<?n=2&last=d>; rel="next"`,
+		}},
+	}
+	for _, tt := range tests {
+		server := pagedRegistry(t, entries, tt.links)
+		client := NewRegistryClient(server.URL, "", "")
+
+		var pages [][]string
+		err := client.WalkRepositories(context.Background(), func(page []string) error {
+			pages = append(pages, page)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: WalkRepositories failed: %v", tt.name, err)
+			continue
+		}
+		if len(pages) != 3 || !slices.Equal(slices.Concat(pages...), entries) {
+			t.Errorf("%s: walked pages %v, want %v in 3 pages", tt.name, pages, entries)
+		}
+	}
+}
+
+func TestListTagsFollowsRelativeLinks(t *testing.T) {
+	entries := []string{"v1", "v2", "v3"}
+	server := pagedRegistry(t, entries, map[string]string{
+		"v2": `<list?n=2&last=v2>; rel="next"`,
+	})
+
+	tags, err := NewRegistryClient(server.URL, "", "").ListTags(context.Background(), "team/app")
+	if err != nil {
+		t.Fatalf("ListTags failed: %v", err)
+	}
+	if !slices.Equal(tags, entries) {
+		t.Errorf("ListTags = %v, want %v", tags, entries)
+	}
+}
+
+func TestWalkPagesStopsOnLoops(t *testing.T) {
+	entries := []string{"a", "b", "c", "d"}
+	server := pagedRegistry(t, entries, map[string]string{
+		"b": `</v2/_catalog?n=2&last=b>; rel="next"`,
+		// The last page points back to the first
+		"d": fmt.Sprintf(`</v2/_catalog?n=%d>; rel="next"`, catalogPageSize),
+	})
+
+	pages := 0
+	err := NewRegistryClient(server.URL, "", "").WalkRepositories(context.Background(), func(page []string) error {
+		pages++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "loops back") {
+		t.Fatalf("WalkRepositories = %v, want a pagination loop error", err)
+	}
+	if pages != 2 {
+		t.Errorf("walked %d pages before the loop, want 2", pages)
+	}
+}
+
+func TestWalkPagesStopsOnCallbackError(t *testing.T) {
+	entries := []string{"a", "b", "c"}
+	server := pagedRegistry(t, entries, map[string]string{
+		"b": `</v2/_catalog?n=2&last=b>; rel="next"`,
+	})
+
+	stop := errors.New("stop")
+	pages := 0
+	err := NewRegistryClient(server.URL, "", "").WalkRepositories(context.Background(), func(page []string) error {
+		pages++
+		return stop
+	})
+	if !errors.Is(err, stop) || pages != 1 {
+		t.Errorf("WalkRepositories = %v after %d pages, want the callback error after 1", err, pages)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// RegistryEventsMediaType is the content type of registry notification envelopes
+const RegistryEventsMediaType = "application/vnd.docker.distribution.events.v1+json"
+
+// Actions of registry notification events
+const (
+	RegistryEventPush   = "push"
+	RegistryEventPull   = "pull"
+	RegistryEventDelete = "delete"
+	RegistryEventMount  = "mount"
+)
+
+// RegistryEnvelope is the body a registry posts to its notification endpoints
+type RegistryEnvelope struct {
+	Events []RegistryEvent `json:"events"`
+}
+
+// RegistryEvent is a single push, pull, delete or mount in the registry
+type RegistryEvent struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Target    struct {
+		MediaType  string `json:"mediaType"`
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		Method    string `json:"method"`
+		UserAgent string `json:"useragent"`
+	} `json:"request"`
+}
+
+// RegistryEventsResult tells what was done with a batch of registry events
+type RegistryEventsResult struct {
+	Queued  int `json:"queued"`
+	Pulls   int `json:"pulls"`
+	Ignored int `json:"ignored"`
+}
+
+// HandleRegistryEvents queues targeted syncs for pushed and deleted manifests
+// and counts pulls. Pushes and deletes by tag sync that tag, other deletes
+// sync the whole image so tags pointing at a deleted digest are removed.
+func (s *SyncService) HandleRegistryEvents(ctx context.Context, events []RegistryEvent) (*RegistryEventsResult, error) {
+	result := &RegistryEventsResult{}
+	pulls := make(map[string]int)
+
+	for _, e := range events {
+		repoPath := e.Target.Repository
+		if repoPath == "" {
+			result.Ignored++
+			continue
+		}
+		namespace, imageName := utils.SplitRepositoryPath(repoPath)
+
+		switch e.Action {
+		case RegistryEventPush:
+			// Blob uploads and untagged manifests (e.g. the platform manifests
+			// of an index) are picked up with the tag that references them
+			if !isManifestMediaType(e.Target.MediaType) || e.Target.Tag == "" {
+				result.Ignored++
+				continue
+			}
+			if s.queueSync(models.SyncTriggerWebhook, syncTarget{Namespace: namespace, Image: imageName, Tag: e.Target.Tag}) {
+				result.Queued++
+			}
+
+		case RegistryEventDelete:
+			target := syncTarget{Namespace: namespace, Image: imageName, Tag: e.Target.Tag}
+			if s.queueSync(models.SyncTriggerWebhook, target) {
+				result.Queued++
+			}
+
+		case RegistryEventPull:
+			// Only count pulls by tag, pulls by digest are mostly clients
+			// resolving the platform manifest of a tag they just pulled.
+			// Requests made by our own sync don't count either.
+			if !isManifestMediaType(e.Target.MediaType) || e.Target.Tag == "" ||
+				e.Request.Method == http.MethodHead ||
+				strings.HasPrefix(e.Request.UserAgent, registryUserAgent) {
+				result.Ignored++
+				continue
+			}
+			pulls[repoPath]++
+
+		default:
+			result.Ignored++
+		}
+	}
+
+	if err := s.countPulls(ctx, pulls, result); err != nil {
+		return result, err
+	}
+
+	log.Printf("Registry notification: queued %d syncs, counted %d pulls", result.Queued, result.Pulls)
+	return result, nil
+}
+
+// countPulls adds the pulls per repository path to the stored images
+func (s *SyncService) countPulls(ctx context.Context, pulls map[string]int, result *RegistryEventsResult) error {
+	if len(pulls) == 0 {
+		return nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for repoPath, n := range pulls {
+		namespace, imageName := utils.SplitRepositoryPath(repoPath)
+		if err := s.imageRepo.IncrementPullCount(ctx, namespace, imageName, n); err != nil {
+			return fmt.Errorf("failed to count pulls of %s: %w", repoPath, err)
+		}
+		result.Pulls += n
+	}
+	return nil
+}
+
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v1+prettyjws",
+		"application/vnd.docker.distribution.manifest.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.oci.image.index.v1+json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestHandleRegistryEventsCountsPulls(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.pushImage("team/app", "v1", `{"os":"linux"}`, "layer")
+	s := newTestSyncService(t, registry.server.URL)
+	syncAll(t, s)
+	ctx := context.Background()
+
+	pull := func(method, userAgent, mediaType, tag string) RegistryEvent {
+		e := RegistryEvent{Action: RegistryEventPull}
+		e.Target.Repository = "team/app"
+		e.Target.MediaType = mediaType
+		e.Target.Tag = tag
+		e.Request.Method = method
+		e.Request.UserAgent = userAgent
+		return e
+	}
+	events := []RegistryEvent{
+		pull(http.MethodGet, "docker/27.3.1 go/go1.22.7", ociManifestType, "v1"),
+		pull(http.MethodGet, "containerd/2.0.0", ociIndexType, "v1"),
+		// Checking whether a tag changed isn't a pull
+		pull(http.MethodHead, "docker/27.3.1 go/go1.22.7", ociManifestType, "v1"),
+		// Neither are the requests of our own sync
+		pull(http.MethodGet, registryUserAgent, ociManifestType, "v1"),
+		pull(http.MethodGet, registryUserAgent+"/1.0", ociManifestType, "v1"),
+		// Platform manifests by digest and blobs
+		pull(http.MethodGet, "docker/27.3.1 go/go1.22.7", ociManifestType, ""),
+		pull(http.MethodGet, "docker/27.3.1 go/go1.22.7", "application/vnd.oci.image.layer.v1.tar+gzip", ""),
+	}
+
+	result, err := s.HandleRegistryEvents(ctx, events)
+	if err != nil {
+		t.Fatalf("HandleRegistryEvents failed: %v", err)
+	}
+	if result.Pulls != 2 || result.Ignored != 5 || result.Queued != 0 {
+		t.Errorf("HandleRegistryEvents = %+v, want 2 pulls and 5 ignored", result)
+	}
+	image, err := s.imageRepo.GetImage(ctx, "team", "app")
+	if err != nil || image == nil {
+		t.Fatalf("failed to get image: %v", err)
+	}
+	if image.PullCount != 2 {
+		t.Errorf("image has %d pulls, want 2", image.PullCount)
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+)
+
+// ErrInvalidRetentionRule is returned for retention rules with invalid
+// settings or a scope that already has a rule
+var ErrInvalidRetentionRule = errors.New("invalid retention rule")
+
+// ErrRetentionRuleNotFound is returned for retention rules that don't exist
+var ErrRetentionRuleNotFound = errors.New("retention rule not found")
+
+// semverRelease matches release versions like 1.2.3 or v1.2.3, but not
+// pre-releases like 1.2.3-rc.1
+var semverRelease = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(\+[0-9A-Za-z.-]+)?$`)
+
+// ListRetentionRules returns all retention rules
+func (s *SyncService) ListRetentionRules(ctx context.Context) ([]models.RetentionRule, error) {
+	return s.ruleRepo.ListRules(ctx)
+}
+
+// GetRetentionRule returns a retention rule
+func (s *SyncService) GetRetentionRule(ctx context.Context, id uint) (*models.RetentionRule, error) {
+	rule, err := s.ruleRepo.GetRule(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention rule: %w", err)
+	}
+	if rule == nil {
+		return nil, ErrRetentionRuleNotFound
+	}
+	return rule, nil
+}
+
+// SaveRetentionRule creates a retention rule, or updates the settings of rule
+// id if it isn't zero, and works out when it runs next
+func (s *SyncService) SaveRetentionRule(ctx context.Context, id uint, settings models.RetentionRule) (*models.RetentionRule, error) {
+	if settings.Cron == "" {
+		settings.Cron = models.SyncScheduleManual
+	}
+	next, err := validateRetentionRule(&settings)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &models.RetentionRule{}
+	if id != 0 {
+		if rule, err = s.GetRetentionRule(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := s.ruleRepo.FindRule(ctx, settings.Namespace, settings.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find retention rule: %w", err)
+	}
+	if existing != nil && existing.ID != id {
+		return nil, fmt.Errorf("%w: %s already has rule %d", ErrInvalidRetentionRule, ruleScope(&settings), existing.ID)
+	}
+
+	rule.Namespace = settings.Namespace
+	rule.Image = settings.Image
+	rule.KeepLatest = settings.KeepLatest
+	rule.OlderThan = settings.OlderThan
+	rule.TagPattern = settings.TagPattern
+	rule.KeepSemver = settings.KeepSemver
+	rule.Cron = settings.Cron
+	rule.NextRun = next
+
+	if err := s.ruleRepo.SaveRule(ctx, rule); err != nil {
+		return nil, fmt.Errorf("failed to save retention rule: %w", err)
+	}
+	return rule, nil
+}
+
+// DeleteRetentionRule deletes a retention rule. The plans it made are kept.
+func (s *SyncService) DeleteRetentionRule(ctx context.Context, id uint) error {
+	if _, err := s.GetRetentionRule(ctx, id); err != nil {
+		return err
+	}
+	if err := s.ruleRepo.DeleteRule(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete retention rule: %w", err)
+	}
+	return nil
+}
+
+// validateRetentionRule checks the settings of a rule and returns when it runs
+// next after now
+func validateRetentionRule(rule *models.RetentionRule) (*time.Time, error) {
+	if rule.Namespace == "" {
+		return nil, fmt.Errorf("%w: a namespace is required", ErrInvalidRetentionRule)
+	}
+	if rule.KeepLatest < 0 {
+		return nil, fmt.Errorf("%w: keepLatest can't be negative", ErrInvalidRetentionRule)
+	}
+	if rule.KeepLatest == 0 && rule.OlderThan == "" && rule.TagPattern == "" {
+		return nil, fmt.Errorf("%w: set keepLatest, olderThan or tagPattern, the rule would delete every tag", ErrInvalidRetentionRule)
+	}
+	if rule.OlderThan != "" {
+		if _, err := parseAge(rule.OlderThan); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRetentionRule, err)
+		}
+	}
+	if rule.TagPattern != "" {
+		if _, err := regexp.Compile(rule.TagPattern); err != nil {
+			return nil, fmt.Errorf("%w: invalid tag pattern: %v", ErrInvalidRetentionRule, err)
+		}
+	}
+
+	next, err := nextCronRun(rule.Cron, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRetentionRule, err)
+	}
+	return next, nil
+}
+
+// ruleScope describes what a rule applies to, for messages
+func ruleScope(rule *models.RetentionRule) string {
+	if rule.Image == "" {
+		return "namespace " + rule.Namespace
+	}
+	return "image " + rule.Namespace + "/" + rule.Image
+}
+
+// PreviewRetention stores what running a rule would delete as a plan, without
+// deleting anything. The plan can be run later through RunDeletionPlan.
+func (s *SyncService) PreviewRetention(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	rule, err := s.GetRetentionRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.planRetention(ctx, rule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.CreatePlan(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to store deletion plan: %w", err)
+	}
+	return plan, nil
+}
+
+// RunRetention plans a rule and runs the plan right away
+func (s *SyncService) RunRetention(ctx context.Context, id uint) (*models.DeletionPlan, error) {
+	plan, err := s.PreviewRetention(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.RunDeletionPlan(ctx, plan.ID)
+}
+
+// planRetention works out which tags a rule deletes from the stored tags. The
+// plan never deletes a manifest a kept tag points to or its index references.
+func (s *SyncService) planRetention(ctx context.Context, rule *models.RetentionRule, now time.Time) (*models.DeletionPlan, error) {
+	var pattern *regexp.Regexp
+	if rule.TagPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(rule.TagPattern); err != nil {
+			return nil, fmt.Errorf("%w: invalid tag pattern: %v", ErrInvalidRetentionRule, err)
+		}
+	}
+	var cutoff time.Time
+	if rule.OlderThan != "" {
+		age, err := parseAge(rule.OlderThan)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRetentionRule, err)
+		}
+		cutoff = now.Add(-age)
+	}
+
+	images, err := s.imageRepo.FindImages(ctx, repository.ImageScope{Namespace: rule.Namespace, Image: rule.Image})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find images: %w", err)
+	}
+
+	// Images with a rule of their own are left to it
+	var overridden []string
+	if rule.Image == "" {
+		rules, err := s.ruleRepo.ListRules(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load retention rules: %w", err)
+		}
+		for _, other := range rules {
+			if other.Namespace == rule.Namespace && other.Image != "" {
+				overridden = append(overridden, other.Image)
+			}
+		}
+	}
+
+	var selections []tagSelection
+	for _, image := range images {
+		if slices.Contains(overridden, image.Name) {
+			continue
+		}
+
+		tags := expiredTags(rule, image.Tags, pattern, cutoff)
+		if len(tags) > 0 {
+			selections = append(selections, tagSelection{
+				namespace: rule.Namespace,
+				image:     image.Name,
+				repoPath:  image.FullName,
+				tags:      tags,
+			})
+		}
+	}
+
+	plan, err := s.planDeletion(ctx, models.DeletionSourceRetention, selections, false)
+	if err != nil {
+		return nil, err
+	}
+	plan.RuleID = rule.ID
+	return plan, nil
+}
+
+// expiredTags returns the names of the tags of an image a rule deletes
+func expiredTags(rule *models.RetentionRule, tags []models.Tag, pattern *regexp.Regexp, cutoff time.Time) []string {
+	// Newest first, by when the image was built if the registry said and
+	// by when the tag was first synced otherwise
+	sorted := slices.Clone(tags)
+	slices.SortStableFunc(sorted, func(a, b models.Tag) int {
+		return tagAge(b).Compare(tagAge(a))
+	})
+
+	var expired []string
+	for i, tag := range sorted {
+		if i < rule.KeepLatest {
+			continue
+		}
+		if rule.KeepSemver && semverRelease.MatchString(tag.Name) {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(tag.Name) {
+			continue
+		}
+		if !cutoff.IsZero() {
+			// Tags of unknown age are never old enough
+			created, ok := imageCreated(tag)
+			if !ok || !created.Before(cutoff) {
+				continue
+			}
+		}
+		expired = append(expired, tag.Name)
+	}
+	return expired
+}
+
+// tagAge returns the time tags are ordered by for keepLatest
+func tagAge(tag models.Tag) time.Time {
+	if created, ok := imageCreated(tag); ok {
+		return created
+	}
+	return tag.CreatedAt
+}
+
+// runDueRetention starts the retention rules whose next run is due
+func (s *SyncService) runDueRetention(ctx context.Context, now time.Time) {
+	rules, err := s.ruleRepo.ListRules(ctx)
+	if err != nil {
+		log.Printf("Failed to load retention rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.Cron == models.SyncScheduleManual {
+			continue
+		}
+		if rule.NextRun != nil && rule.NextRun.After(now) {
+			continue
+		}
+
+		next, err := nextCronRun(rule.Cron, now)
+		if err != nil {
+			log.Printf("Invalid retention rule %d for %s: %v", rule.ID, ruleScope(&rule), err)
+			continue
+		}
+
+		lastRun := rule.LastRun
+		if s.startRetention(rule.ID) {
+			lastRun = &now
+		} else {
+			log.Printf("Skipping retention rule %d, its previous run is still in progress", rule.ID)
+		}
+
+		if err := s.ruleRepo.UpdateRuleState(ctx, rule.ID, lastRun, next); err != nil {
+			log.Printf("Failed to update retention rule %d: %v", rule.ID, err)
+		}
+	}
+}
+
+// startRetention runs a rule in the background unless it is running already
+func (s *SyncService) startRetention(id uint) bool {
+	s.mu.Lock()
+	if s.retaining[id] {
+		s.mu.Unlock()
+		return false
+	}
+	s.retaining[id] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.retaining, id)
+			s.mu.Unlock()
+		}()
+
+		plan, err := s.RunRetention(s.baseCtx, id)
+		if err != nil {
+			log.Printf("Retention rule %d failed: %v", id, err)
+			return
+		}
+		deleted := 0
+		for _, item := range plan.Items {
+			if item.Status == models.DeletionItemDeleted {
+				deleted++
+			}
+		}
+		log.Printf("Retention rule %d deleted %d of %d manifests (plan %d, %s)", id, deleted, len(plan.Items), plan.ID, plan.Status)
+	}()
+	return true
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+func TestExpiredTags(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	tag := func(name string, daysOld int) models.Tag {
+		tag := models.Tag{Name: name}
+		if daysOld >= 0 {
+			tag.Metadata.Created = now.AddDate(0, 0, -daysOld).Format(time.RFC3339)
+		}
+		return tag
+	}
+	// Out of order, "unknown" has no build time and sorts last
+	tags := []models.Tag{
+		tag("dev-1", 5), tag("unknown", -1), tag("v1.2.0", 1), tag("dev-2", 3),
+		tag("v1.1.0", 4), tag("dev-3", 2),
+	}
+
+	tests := []struct {
+		name    string
+		rule    models.RetentionRule
+		pattern string
+		cutoff  time.Time
+		want    []string
+	}{
+		{"keep latest", models.RetentionRule{KeepLatest: 2}, "", time.Time{},
+			[]string{"dev-2", "v1.1.0", "dev-1", "unknown"}},
+		// Releases kept by keepSemver still take up a slot
+		{"keep semver", models.RetentionRule{KeepLatest: 2, KeepSemver: true}, "", time.Time{},
+			[]string{"dev-2", "dev-1", "unknown"}},
+		// Tags the pattern doesn't match still take up a slot
+		{"pattern", models.RetentionRule{KeepLatest: 2}, "^dev-", time.Time{},
+			[]string{"dev-2", "dev-1"}},
+		// Tags of unknown age are never old enough
+		{"older than", models.RetentionRule{}, "", now.AddDate(0, 0, -3),
+			[]string{"v1.1.0", "dev-1"}},
+		{"all conditions", models.RetentionRule{KeepLatest: 1, KeepSemver: true}, "^dev-", now.AddDate(0, 0, -2),
+			[]string{"dev-2", "dev-1"}},
+	}
+	for _, tt := range tests {
+		var pattern *regexp.Regexp
+		if tt.pattern != "" {
+			pattern = regexp.MustCompile(tt.pattern)
+		}
+		if got := expiredTags(&tt.rule, tags, pattern, tt.cutoff); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expired %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanRetentionLeavesImagesWithARuleOfTheirOwn(t *testing.T) {
+	registry := newFakeRegistry(t)
+	for _, repo := range []string{"team/app", "team/web"} {
+		for day, tagName := range []string{"a", "b", "c"} {
+			config := fmt.Sprintf(`{"created":"2026-10-0%dT00:00:00Z"}`, day+1)
+			registry.pushImage(repo, tagName, config, repo+tagName)
+		}
+	}
+	s := newTestSyncService(t, registry.server.URL)
+	syncAll(t, s)
+	ctx := context.Background()
+
+	namespaceRule, err := s.SaveRetentionRule(ctx, 0, models.RetentionRule{Namespace: "team", KeepLatest: 1, Cron: "manual"})
+	if err != nil {
+		t.Fatalf("failed to save namespace rule: %v", err)
+	}
+	imageRule, err := s.SaveRetentionRule(ctx, 0, models.RetentionRule{Namespace: "team", Image: "app", KeepLatest: 2, Cron: "manual"})
+	if err != nil {
+		t.Fatalf("failed to save image rule: %v", err)
+	}
+
+	tests := []struct {
+		rule *models.RetentionRule
+		want []string // References of the planned tags
+	}{
+		{namespaceRule, []string{"web:a", "web:b"}},
+		{imageRule, []string{"app:a"}},
+	}
+	for _, tt := range tests {
+		plan, err := s.planRetention(ctx, tt.rule, time.Now())
+		if err != nil {
+			t.Errorf("planRetention of %s failed: %v", ruleScope(tt.rule), err)
+			continue
+		}
+		var got []string
+		for _, item := range plan.Items {
+			for _, tagName := range item.Tags {
+				got = append(got, item.Image+":"+tagName)
+			}
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s planned %v, want %v", ruleScope(tt.rule), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Config key the sync filters are stored under as JSON. They are only
+// changed through UpdateSyncFilters, which validates them.
+const SyncFiltersConfigKey = "sync_filters"
+
+// ErrInvalidSyncFilters is returned when a filter pattern does not compile
+var ErrInvalidSyncFilters = errors.New("invalid sync filters")
+
+// ErrExcludedFromSync is returned by targeted syncs of a repository or tag
+// that the sync filters exclude
+var ErrExcludedFromSync = errors.New("excluded by sync filters")
+
+// SyncFilterRule matches repository paths or tag names. Globs must match the
+// whole name, "*" stops at slashes and "**" does not. Regular expressions
+// match anywhere unless anchored.
+type SyncFilterRule struct {
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex,omitempty"`
+}
+
+// SyncFilters decide which repositories and tags get synced. A name is synced
+// when it matches an include rule, or there are none, and no exclude rule.
+type SyncFilters struct {
+	IncludeRepositories []SyncFilterRule `json:"includeRepositories"`
+	ExcludeRepositories []SyncFilterRule `json:"excludeRepositories"`
+	IncludeTags         []SyncFilterRule `json:"includeTags"`
+	ExcludeTags         []SyncFilterRule `json:"excludeTags"`
+}
+
+// SyncFilterPreview lists which catalog entries a set of filters would sync
+type SyncFilterPreview struct {
+	Included     int                     `json:"included"`
+	Excluded     int                     `json:"excluded"`
+	Repositories []RepositoryFilterMatch `json:"repositories"`
+}
+
+// RepositoryFilterMatch tells whether a repository, and optionally each of
+// its tags, would be synced
+type RepositoryFilterMatch struct {
+	Repository string           `json:"repository"`
+	Included   bool             `json:"included"`
+	Tags       []TagFilterMatch `json:"tags,omitempty"`
+}
+
+type TagFilterMatch struct {
+	Tag      string `json:"tag"`
+	Included bool   `json:"included"`
+}
+
+// syncFilter is the compiled form of SyncFilters. A nil filter includes everything.
+type syncFilter struct {
+	includeRepositories []*regexp.Regexp
+	excludeRepositories []*regexp.Regexp
+	includeTags         []*regexp.Regexp
+	excludeTags         []*regexp.Regexp
+}
+
+func compileSyncFilters(f SyncFilters) (*syncFilter, error) {
+	compiled := &syncFilter{}
+	lists := []struct {
+		rules []SyncFilterRule
+		dst   *[]*regexp.Regexp
+	}{
+		{f.IncludeRepositories, &compiled.includeRepositories},
+		{f.ExcludeRepositories, &compiled.excludeRepositories},
+		{f.IncludeTags, &compiled.includeTags},
+		{f.ExcludeTags, &compiled.excludeTags},
+	}
+
+	for _, list := range lists {
+		for _, rule := range list.rules {
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("%w: empty pattern", ErrInvalidSyncFilters)
+			}
+			re, err := rule.compile()
+			if err != nil {
+				return nil, fmt.Errorf("%w: pattern %q: %v", ErrInvalidSyncFilters, rule.Pattern, err)
+			}
+			*list.dst = append(*list.dst, re)
+		}
+	}
+
+	return compiled, nil
+}
+
+func (r SyncFilterRule) compile() (*regexp.Regexp, error) {
+	if r.Regex {
+		return regexp.Compile(r.Pattern)
+	}
+	return globToRegexp(r.Pattern)
+}
+
+// globToRegexp converts a glob with *, ** and ? wildcards into an anchored regular expression
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func (f *syncFilter) repositoryIncluded(repoPath string) bool {
+	if f == nil {
+		return true
+	}
+	return matchFilter(repoPath, f.includeRepositories, f.excludeRepositories)
+}
+
+func (f *syncFilter) tagIncluded(tagName string) bool {
+	if f == nil {
+		return true
+	}
+	return matchFilter(tagName, f.includeTags, f.excludeTags)
+}
+
+func matchFilter(name string, include, exclude []*regexp.Regexp) bool {
+	if len(include) > 0 && !matchAny(name, include) {
+		return false
+	}
+	return !matchAny(name, exclude)
+}
+
+func matchAny(name string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSyncFilters returns the stored sync filters, empty filters if none are stored
+func (s *SyncService) GetSyncFilters(ctx context.Context) (*SyncFilters, error) {
+	config, err := s.configRepo.Get(ctx, SyncFiltersConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync filters config: %w", err)
+	}
+
+	filters := &SyncFilters{}
+	if config == nil || config.Value == "" {
+		return filters, nil
+	}
+	if err := json.Unmarshal([]byte(config.Value), filters); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSyncFilters, err)
+	}
+	return filters, nil
+}
+
+// UpdateSyncFilters validates and stores the sync filters. They apply from the next sync on.
+func (s *SyncService) UpdateSyncFilters(ctx context.Context, filters SyncFilters) error {
+	if _, err := compileSyncFilters(filters); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(filters)
+	if err != nil {
+		return fmt.Errorf("failed to encode sync filters: %w", err)
+	}
+	if err := s.configRepo.Update(ctx, SyncFiltersConfigKey, string(value)); err != nil {
+		return fmt.Errorf("failed to update sync filters: %w", err)
+	}
+	return nil
+}
+
+// PreviewSyncFilters walks the registry catalog and reports which repositories,
+// and with withTags which tags of included repositories, the filters would sync
+func (s *SyncService) PreviewSyncFilters(ctx context.Context, filters SyncFilters, withTags bool) (*SyncFilterPreview, error) {
+	filter, err := compileSyncFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &SyncFilterPreview{Repositories: []RepositoryFilterMatch{}}
+	err = s.registry.WalkRepositories(ctx, func(repositories []string) error {
+		for _, repoPath := range repositories {
+			match := RepositoryFilterMatch{
+				Repository: repoPath,
+				Included:   filter.repositoryIncluded(repoPath),
+			}
+
+			if match.Included {
+				preview.Included++
+			} else {
+				preview.Excluded++
+			}
+
+			if match.Included && withTags {
+				err := s.registry.WalkTags(ctx, repoPath, func(tags []string) error {
+					for _, tagName := range tags {
+						match.Tags = append(match.Tags, TagFilterMatch{Tag: tagName, Included: filter.tagIncluded(tagName)})
+					}
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("failed to list tags of %s: %w", repoPath, err)
+				}
+			}
+
+			preview.Repositories = append(preview.Repositories, match)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// loadSyncFilter compiles the stored sync filters for a run
+func (s *SyncService) loadSyncFilter(ctx context.Context) (*syncFilter, error) {
+	filters, err := s.GetSyncFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return compileSyncFilters(*filters)
+}
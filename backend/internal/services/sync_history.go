@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// recordRunStart stores a new running sync in the history
+func (s *SyncService) recordRunStart(ctx context.Context, result *SyncResult) (*models.SyncRun, error) {
+	record := &models.SyncRun{
+		Trigger:   result.Trigger,
+		Scope:     result.Scope,
+		Status:    models.SyncRunStatusRunning,
+		StartedAt: result.StartedAt,
+	}
+	if err := s.syncRunRepo.CreateRun(ctx, record); err != nil {
+		return nil, err
+	}
+	result.RunID = record.ID
+	return record, nil
+}
+
+// recordRunEnd stores the outcome of a sync run and prunes old runs beyond
+// the retention limit. Full and targeted syncs are kept separately, so
+// webhook pushes don't push the full syncs out of the history.
+func (s *SyncService) recordRunEnd(ctx context.Context, record *models.SyncRun, result *SyncResult, runErr error) {
+	// Record the outcome even if the run itself was cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	finishedAt := result.FinishedAt
+	if finishedAt.IsZero() {
+		finishedAt = time.Now()
+	}
+
+	record.FinishedAt = &finishedAt
+	record.Repositories = result.Repositories
+	record.TagsCreated = result.TagsCreated
+	record.TagsUpdated = result.TagsUpdated
+	record.TagsDeleted = result.TagsDeleted
+
+	for _, e := range result.Errors {
+		record.Errors = append(record.Errors, models.SyncRunError{
+			Repository: e.Repository,
+			Tag:        e.Tag,
+			Message:    e.Error,
+		})
+	}
+
+	switch {
+	case runErr != nil:
+		record.Status = models.SyncRunStatusFailed
+		record.Error = runErr.Error()
+	case len(result.Errors) > 0:
+		record.Status = models.SyncRunStatusPartial
+	default:
+		record.Status = models.SyncRunStatusSucceeded
+	}
+
+	if err := s.syncRunRepo.UpdateRun(ctx, record); err != nil {
+		log.Printf("Failed to record sync run %d: %v", record.ID, err)
+		return
+	}
+
+	if s.runRetention > 0 {
+		if err := s.syncRunRepo.PruneRuns(ctx, s.runRetention, record.Scope != ""); err != nil {
+			log.Printf("Failed to prune sync run history: %v", err)
+		}
+	}
+}
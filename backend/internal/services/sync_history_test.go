@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+func TestRecordRunEndPrunesFullAndTargetedRunsSeparately(t *testing.T) {
+	s := newTestSyncService(t, "http://registry.invalid")
+	s.runRetention = 3
+	ctx := context.Background()
+
+	record := func(trigger, scope string) uint {
+		result := &SyncResult{Trigger: trigger, Scope: scope, Errors: []RepositorySyncError{{Repository: "team/app", Error: "failed"}}}
+		run, err := s.recordRunStart(ctx, result)
+		if err != nil {
+			t.Fatalf("failed to record sync run: %v", err)
+		}
+		s.recordRunEnd(ctx, run, result, nil)
+		return run.ID
+	}
+
+	var full []uint
+	for i := 0; i < 4; i++ {
+		full = append(full, record(models.SyncTriggerManual, ""))
+	}
+	// A burst of webhook pushes doesn't push the full syncs out
+	for i := 0; i < 10; i++ {
+		record(models.SyncTriggerWebhook, "team/app:latest")
+	}
+
+	runs, total, err := s.syncRunRepo.ListRuns(ctx, 1, 100)
+	if err != nil {
+		t.Fatalf("failed to list sync runs: %v", err)
+	}
+	if total != 6 {
+		t.Fatalf("history has %d runs, want 3 full and 3 targeted", total)
+	}
+	var fullKept []uint
+	for _, run := range runs {
+		if run.Scope == "" {
+			fullKept = append(fullKept, run.ID)
+		}
+	}
+	if len(fullKept) != 3 || fullKept[2] != full[1] {
+		t.Errorf("kept full runs %v, want the newest 3 of %v", fullKept, full)
+	}
+
+	// The errors of pruned runs go with them
+	if run, err := s.syncRunRepo.GetRun(ctx, full[0]); err != nil || run != nil {
+		t.Errorf("pruned run %d is still stored: %v", full[0], err)
+	}
+	if run, err := s.syncRunRepo.GetRun(ctx, full[1]); err != nil || run == nil || len(run.Errors) != 1 {
+		t.Errorf("kept run %d lost its errors: %+v, %v", full[1], run, err)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Event types sent to subscribers of a sync job. Errors don't use the name
+// "error", which EventSource reserves for connection failures.
+const (
+	SyncEventProgress = "progress"
+	SyncEventError    = "sync-error"
+	SyncEventComplete = "complete"
+)
+
+// Status of a sync job
+const (
+	SyncJobRunning   = "running"
+	SyncJobCompleted = "completed"
+	SyncJobFailed    = "failed"
+)
+
+// Number of finished jobs kept in memory so their result can still be fetched
+const maxFinishedSyncJobs = 20
+
+// Buffered events per subscriber, older progress events are dropped for slow clients
+const syncEventBuffer = 32
+
+// SyncProgress is a snapshot of how far a sync job has come
+type SyncProgress struct {
+	JobID             string                `json:"jobId"`
+	Trigger           string                `json:"trigger"`
+	Status            string                `json:"status"`
+	StartedAt         time.Time             `json:"startedAt"`
+	CurrentRepository string                `json:"currentRepository,omitempty"`
+	RepositoriesDone  int                   `json:"repositoriesDone"`
+	TagsDone          int                   `json:"tagsDone"`
+	TagsTotal         int                   `json:"tagsTotal"`
+	ErrorCount        int                   `json:"errorCount"`
+	Errors            []RepositorySyncError `json:"errors,omitempty"` // Left out of progress and error events
+	Result            *SyncResult           `json:"result,omitempty"`
+	Error             string                `json:"error,omitempty"`
+}
+
+// SyncEvent is sent to job subscribers whenever the progress changes
+type SyncEvent struct {
+	Type     string               `json:"type"`
+	Progress SyncProgress         `json:"progress"`
+	Error    *RepositorySyncError `json:"error,omitempty"`
+}
+
+// SyncJob is a sync running in the background that clients can follow
+type SyncJob struct {
+	ID             string
+	trigger        string
+	skipNamespaces []string // Namespaces the full sync leaves out
+
+	mu          sync.Mutex
+	progress    SyncProgress
+	finished    bool
+	done        chan struct{}
+	subscribers map[chan SyncEvent]struct{}
+}
+
+func newSyncJob(trigger string) *SyncJob {
+	id := newJobID()
+	return &SyncJob{
+		ID:      id,
+		trigger: trigger,
+		progress: SyncProgress{
+			JobID:     id,
+			Trigger:   trigger,
+			Status:    SyncJobRunning,
+			StartedAt: time.Now(),
+			Errors:    []RepositorySyncError{},
+		},
+		done:        make(chan struct{}),
+		subscribers: make(map[chan SyncEvent]struct{}),
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// Progress returns the current progress of the job
+func (j *SyncJob) Progress() SyncProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshot()
+}
+
+// Done is closed when the job has finished
+func (j *SyncJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Subscribe returns a channel receiving the job's events, starting with the
+// current progress. The channel is closed after the complete event, or when
+// the returned function is called.
+func (j *SyncJob) Subscribe() (<-chan SyncEvent, func()) {
+	ch := make(chan SyncEvent, syncEventBuffer)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.finished {
+		ch <- SyncEvent{Type: SyncEventComplete, Progress: j.snapshot()}
+		close(ch)
+		return ch, func() {}
+	}
+
+	ch <- SyncEvent{Type: SyncEventProgress, Progress: j.snapshot()}
+	j.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// snapshot copies the progress, the caller must hold j.mu
+func (j *SyncJob) snapshot() SyncProgress {
+	p := j.progress
+	p.Errors = append([]RepositorySyncError(nil), j.progress.Errors...)
+	return p
+}
+
+// eventProgress is the progress without the error list, which subscribers
+// already received one by one. The caller must hold j.mu.
+func (j *SyncJob) eventProgress() SyncProgress {
+	p := j.progress
+	p.Errors = nil
+	return p
+}
+
+// publish sends an event to every subscriber without blocking, the caller must hold j.mu
+func (j *SyncJob) publish(ev SyncEvent) {
+	for ch := range j.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Subscriber is behind, it will catch up with the next snapshot
+		}
+	}
+}
+
+func (j *SyncJob) update(fn func(p *SyncProgress)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished {
+		return
+	}
+	fn(&j.progress)
+	j.publish(SyncEvent{Type: SyncEventProgress, Progress: j.eventProgress()})
+}
+
+func (j *SyncJob) repositoryStarted(name string) {
+	j.update(func(p *SyncProgress) { p.CurrentRepository = name })
+}
+
+func (j *SyncJob) repositoryDone() {
+	j.update(func(p *SyncProgress) { p.RepositoriesDone++ })
+}
+
+func (j *SyncJob) tagsFound(n int) {
+	j.update(func(p *SyncProgress) { p.TagsTotal += n })
+}
+
+func (j *SyncJob) tagDone() {
+	j.update(func(p *SyncProgress) { p.TagsDone++ })
+}
+
+func (j *SyncJob) addError(e RepositorySyncError) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished {
+		return
+	}
+	j.progress.Errors = append(j.progress.Errors, e)
+	j.progress.ErrorCount++
+	j.publish(SyncEvent{Type: SyncEventError, Progress: j.eventProgress(), Error: &e})
+}
+
+// finish stores the outcome, sends the complete event and closes all subscriptions
+func (j *SyncJob) finish(result *SyncResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished {
+		return
+	}
+
+	j.finished = true
+	j.progress.Result = result
+	j.progress.CurrentRepository = ""
+	if err != nil {
+		j.progress.Status = SyncJobFailed
+		j.progress.Error = err.Error()
+	} else {
+		j.progress.Status = SyncJobCompleted
+	}
+
+	ev := SyncEvent{Type: SyncEventComplete, Progress: j.snapshot()}
+	for ch := range j.subscribers {
+		// Make room so the complete event is never dropped
+		select {
+		case ch <- ev:
+		default:
+			<-ch
+			ch <- ev
+		}
+		close(ch)
+	}
+	j.subscribers = nil
+	close(j.done)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// Maximum number of targeted syncs waiting in the queue
+const maxQueuedSyncs = 1000
+
+// syncTarget is a namespace, image or tag to sync. Image and Tag are empty
+// when the whole namespace or image should be synced.
+type syncTarget struct {
+	Namespace string
+	Image     string
+	Tag       string
+}
+
+func (t syncTarget) String() string {
+	switch {
+	case t.Tag != "":
+		return t.Namespace + "/" + t.Image + ":" + t.Tag
+	case t.Image != "":
+		return t.Namespace + "/" + t.Image
+	default:
+		return t.Namespace
+	}
+}
+
+type queuedSync struct {
+	target  syncTarget
+	trigger string
+}
+
+// syncQueue holds targeted syncs waiting to run. A target that is already
+// waiting is not queued a second time.
+type syncQueue struct {
+	mu      sync.Mutex
+	pending []queuedSync
+	queued  map[syncTarget]struct{}
+	wake    chan struct{}
+}
+
+func newSyncQueue() *syncQueue {
+	return &syncQueue{
+		queued: make(map[syncTarget]struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// push adds a target to the queue, it returns false if the queue is full
+func (q *syncQueue) push(trigger string, target syncTarget) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queued[target]; ok {
+		return true
+	}
+	if len(q.pending) >= maxQueuedSyncs {
+		return false
+	}
+
+	q.queued[target] = struct{}{}
+	q.pending = append(q.pending, queuedSync{target: target, trigger: trigger})
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pop takes the oldest target from the queue
+func (q *syncQueue) pop() (queuedSync, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return queuedSync{}, false
+	}
+	item := q.pending[0]
+	q.pending = q.pending[1:]
+	delete(q.queued, item.target)
+	return item, true
+}
+
+// queueSync queues a targeted sync to run in the background
+func (s *SyncService) queueSync(trigger string, target syncTarget) bool {
+	if !s.queue.push(trigger, target) {
+		log.Printf("Sync queue is full, dropping sync of %s", target)
+		return false
+	}
+	return true
+}
+
+// processQueue runs queued syncs one at a time until the service is stopped
+func (s *SyncService) processQueue(ctx context.Context) {
+	for {
+		for {
+			item, ok := s.queue.pop()
+			if !ok {
+				break
+			}
+			s.runQueued(ctx, item)
+		}
+
+		select {
+		case <-s.queue.wake:
+		case <-s.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SyncService) runQueued(ctx context.Context, item queuedSync) {
+	t := item.target
+
+	var err error
+	switch {
+	case t.Tag != "":
+		_, err = s.SyncTag(ctx, item.trigger, t.Namespace, t.Image, t.Tag)
+	case t.Image != "":
+		_, err = s.SyncImage(ctx, item.trigger, t.Namespace, t.Image)
+	default:
+		_, err = s.SyncNamespace(ctx, item.trigger, t.Namespace)
+	}
+
+	// Targets that are gone were removed from the database by the sync
+	// itself, excluded ones are not meant to be synced
+	if err != nil && !errors.Is(err, ErrNotInRegistry) && !errors.Is(err, ErrExcludedFromSync) {
+		log.Printf("Queued sync of %s failed: %v", t, err)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// SyncResult summarises a single sync run
+type SyncResult struct {
+	RunID        uint                  `json:"runId"`
+	Trigger      string                `json:"trigger"`
+	Scope        string                `json:"scope,omitempty"` // Namespace, image or tag of a targeted sync, empty for the whole catalog
+	StartedAt    time.Time             `json:"startedAt"`
+	FinishedAt   time.Time             `json:"finishedAt"`
+	Repositories int                   `json:"repositories"`
+	TagsCreated  int                   `json:"tagsCreated"`
+	TagsUpdated  int                   `json:"tagsUpdated"`
+	TagsDeleted  int                   `json:"tagsDeleted"`
+	Removed      []SyncRemoval         `json:"removed"`
+	Errors       []RepositorySyncError `json:"errors"`
+}
+
+// Kinds of entries the sync removes when they disappear from the registry
+const (
+	RemovedTag        = "tag"
+	RemovedImage      = "image"
+	RemovedRepository = "repository"
+)
+
+// SyncRemoval is a tag, image or namespace the sync removed from the
+// database because it no longer exists in the registry
+type SyncRemoval struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// RepositorySyncError is an error that occurred while syncing a repository,
+// optionally narrowed down to a single tag
+type RepositorySyncError struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Error      string `json:"error"`
+}
+
+// syncRun holds the state shared by all workers of a sync run
+type syncRun struct {
+	repoSem chan struct{}
+	tagSem  chan struct{}
+	job     *SyncJob    // Receives progress updates, may be nil
+	filter  *syncFilter // Repositories and tags to sync, nil syncs everything
+
+	skipNamespaces []string // Namespaces with their own schedule, left out of scheduled full syncs
+
+	mu     sync.Mutex
+	result *SyncResult
+}
+
+func newSyncRun(trigger string, repoWorkers, tagWorkers int) *syncRun {
+	return &syncRun{
+		repoSem: newSemaphore(repoWorkers),
+		tagSem:  newSemaphore(tagWorkers),
+		result: &SyncResult{
+			Trigger:   trigger,
+			StartedAt: time.Now(),
+			Removed:   []SyncRemoval{},
+			Errors:    []RepositorySyncError{},
+		},
+	}
+}
+
+func (r *syncRun) repositorySynced() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Repositories++
+}
+
+// tagSynced counts a tag that was fetched from the registry and stored
+func (r *syncRun) tagSynced(created bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if created {
+		r.result.TagsCreated++
+	} else {
+		r.result.TagsUpdated++
+	}
+}
+
+func (r *syncRun) addRemoval(kind, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Removed = append(r.result.Removed, SyncRemoval{Kind: kind, Name: name})
+	if kind == RemovedTag {
+		r.result.TagsDeleted++
+	}
+}
+
+func (r *syncRun) addError(repository, tag string, err error) {
+	e := RepositorySyncError{
+		Repository: repository,
+		Tag:        tag,
+		Error:      err.Error(),
+	}
+
+	r.mu.Lock()
+	r.result.Errors = append(r.result.Errors, e)
+	r.mu.Unlock()
+
+	if r.job != nil {
+		r.job.addError(e)
+	}
+}
+
+func (r *syncRun) repositoryStarted(name string) {
+	if r.job != nil {
+		r.job.repositoryStarted(name)
+	}
+}
+
+func (r *syncRun) repositoryDone() {
+	if r.job != nil {
+		r.job.repositoryDone()
+	}
+}
+
+func (r *syncRun) tagsFound(n int) {
+	if r.job != nil {
+		r.job.tagsFound(n)
+	}
+}
+
+func (r *syncRun) tagDone() {
+	if r.job != nil {
+		r.job.tagDone()
+	}
+}
+
+// finish marks the run as done and returns its result
+func (r *syncRun) finish() *SyncResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.FinishedAt = time.Now()
+	return r.result
+}
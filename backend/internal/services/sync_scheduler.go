@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// ErrInvalidSchedule is returned for cron expressions that don't parse and
+// for changes that are not allowed on a schedule
+var ErrInvalidSchedule = errors.New("invalid sync schedule")
+
+// SyncScheduleStatus is a schedule together with the cron expression that is
+// actually in effect and whether it is running right now
+type SyncScheduleStatus struct {
+	models.SyncSchedule
+	EffectiveCron string `json:"effectiveCron"`
+	Running       bool   `json:"running"`
+}
+
+// ListSchedules returns the global schedule followed by the namespace overrides
+func (s *SyncService) ListSchedules(ctx context.Context) ([]SyncScheduleStatus, error) {
+	schedules, err := s.scheduleRepo.ListSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync schedules: %w", err)
+	}
+
+	statuses := make([]SyncScheduleStatus, 0, len(schedules))
+	for _, schedule := range schedules {
+		statuses = append(statuses, SyncScheduleStatus{
+			SyncSchedule:  schedule,
+			EffectiveCron: s.effectiveCron(ctx, &schedule),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range statuses {
+		status := &statuses[i]
+		if status.Namespace == "" {
+			status.Running = s.current != nil
+		} else {
+			status.Running = s.scheduled[status.Namespace]
+		}
+	}
+	return statuses, nil
+}
+
+// UpdateSchedule sets the cron expression of the global schedule (empty
+// namespace) or of a namespace override, which is created if needed. The
+// global schedule accepts an empty expression to fall back to the sync interval.
+func (s *SyncService) UpdateSchedule(ctx context.Context, namespace, cron string) (*models.SyncSchedule, error) {
+	if cron == "" && namespace != "" {
+		return nil, fmt.Errorf("%w: a cron expression or %q is required", ErrInvalidSchedule, models.SyncScheduleManual)
+	}
+
+	schedule, err := s.scheduleRepo.GetSchedule(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync schedule: %w", err)
+	}
+	if schedule == nil {
+		schedule = &models.SyncSchedule{Namespace: namespace}
+	}
+	schedule.Cron = cron
+
+	next, err := s.nextRun(ctx, schedule, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	schedule.NextRun = next
+
+	if err := s.scheduleRepo.SaveSchedule(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("failed to save sync schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// DeleteSchedule removes a namespace override, the namespace is then synced
+// by the global schedule again
+func (s *SyncService) DeleteSchedule(ctx context.Context, namespace string) error {
+	if namespace == "" {
+		return fmt.Errorf("%w: the global schedule can't be deleted", ErrInvalidSchedule)
+	}
+	if err := s.scheduleRepo.DeleteSchedule(ctx, namespace); err != nil {
+		return fmt.Errorf("failed to delete sync schedule: %w", err)
+	}
+	return nil
+}
+
+// ensureGlobalSchedule creates the global schedule on first start. It has no
+// next run yet, so the scheduler syncs right away.
+func (s *SyncService) ensureGlobalSchedule(ctx context.Context) error {
+	schedule, err := s.scheduleRepo.GetSchedule(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get global sync schedule: %w", err)
+	}
+	if schedule != nil {
+		return nil
+	}
+	if err := s.scheduleRepo.SaveSchedule(ctx, &models.SyncSchedule{}); err != nil {
+		return fmt.Errorf("failed to create global sync schedule: %w", err)
+	}
+	return nil
+}
+
+// effectiveCron returns the cron expression of a schedule, or for a global
+// schedule without one the sync interval in minutes as an @every expression
+func (s *SyncService) effectiveCron(ctx context.Context, schedule *models.SyncSchedule) string {
+	if schedule.Cron != "" || schedule.Namespace != "" {
+		return schedule.Cron
+	}
+
+	interval := 5
+	if config, err := s.configRepo.Get(ctx, "sync_interval"); err == nil && config != nil {
+		if i, err := strconv.Atoi(config.Value); err == nil && i > 0 {
+			interval = i
+		}
+	}
+	return fmt.Sprintf("@every %dm", interval)
+}
+
+// nextRun returns when a schedule runs next after now, nil for manual schedules
+func (s *SyncService) nextRun(ctx context.Context, schedule *models.SyncSchedule, now time.Time) (*time.Time, error) {
+	return nextCronRun(s.effectiveCron(ctx, schedule), now)
+}
+
+// nextCronRun returns when a cron expression fires next after now, nil for "manual"
+func nextCronRun(cron string, now time.Time) (*time.Time, error) {
+	if cron == models.SyncScheduleManual {
+		return nil, nil
+	}
+
+	parsed, err := parseCron(cron)
+	if err != nil {
+		return nil, err
+	}
+	next := parsed.Next(now)
+	if next.IsZero() {
+		return nil, fmt.Errorf("%q never fires", cron)
+	}
+	return &next, nil
+}
+
+// runScheduler checks the sync schedules and retention rules at the start of every minute until the service is stopped
+func (s *SyncService) runScheduler(ctx context.Context) {
+	ctx, cancel := s.withStop(ctx)
+	defer cancel()
+
+	everyMinute(ctx, func(now time.Time) {
+		s.runDueSchedules(ctx, now)
+		s.runDueRetention(ctx, now)
+	})
+}
+
+// everyMinute calls fn right away and then at the start of every minute until ctx is done
+func everyMinute(ctx context.Context, fn func(now time.Time)) {
+	fn(time.Now())
+
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		select {
+		case <-timer.C:
+			fn(time.Now())
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// runDueSchedules starts every schedule whose next run has come. Schedules
+// without a next run, because they are new or the service was down when they
+// were due, run right away.
+func (s *SyncService) runDueSchedules(ctx context.Context, now time.Time) {
+	schedules, err := s.scheduleRepo.ListSchedules(ctx)
+	if err != nil {
+		log.Printf("Failed to load sync schedules: %v", err)
+		return
+	}
+
+	var overridden []string
+	for _, schedule := range schedules {
+		if schedule.Namespace != "" {
+			overridden = append(overridden, schedule.Namespace)
+		}
+	}
+
+	for _, schedule := range schedules {
+		if s.effectiveCron(ctx, &schedule) == models.SyncScheduleManual {
+			continue
+		}
+		if schedule.NextRun != nil && schedule.NextRun.After(now) {
+			continue
+		}
+
+		next, err := s.nextRun(ctx, &schedule, now)
+		if err != nil {
+			log.Printf("Invalid sync schedule for %q: %v", schedule.Namespace, err)
+			continue
+		}
+
+		lastRun := schedule.LastRun
+		if s.startScheduled(schedule.Namespace, overridden) {
+			lastRun = &now
+		} else {
+			log.Printf("Skipping scheduled sync of %q, the previous one is still running", schedule.Namespace)
+		}
+
+		if err := s.scheduleRepo.UpdateScheduleState(ctx, schedule.ID, lastRun, next); err != nil {
+			log.Printf("Failed to update sync schedule for %q: %v", schedule.Namespace, err)
+		}
+	}
+}
+
+// startScheduled starts the sync of a schedule in the background. The global
+// schedule leaves out namespaces that have their own. It returns false if
+// that sync is still running.
+func (s *SyncService) startScheduled(namespace string, overridden []string) bool {
+	if namespace == "" {
+		job, started := s.beginJob(models.SyncTriggerInterval)
+		if !started {
+			return false
+		}
+		job.skipNamespaces = overridden
+		go func() {
+			if _, err := s.runJob(s.baseCtx, job); err != nil {
+				log.Printf("Scheduled sync failed: %v", err)
+			}
+		}()
+		return true
+	}
+
+	s.mu.Lock()
+	if s.scheduled[namespace] {
+		s.mu.Unlock()
+		return false
+	}
+	s.scheduled[namespace] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.scheduled, namespace)
+			s.mu.Unlock()
+		}()
+		_, err := s.SyncNamespace(s.baseCtx, models.SyncTriggerInterval, namespace)
+		if err != nil && !errors.Is(err, ErrNotInRegistry) && !errors.Is(err, ErrExcludedFromSync) {
+			log.Printf("Scheduled sync of namespace %s failed: %v", namespace, err)
+		}
+	}()
+	return true
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,15 +19,32 @@ import (
 )
 
 type SyncService struct {
-	mu         sync.Mutex
-	isSyncing  bool
-	dockerRepo repository.DockerRepository
-	imageRepo  repository.ImageRepository
-	tagRepo    repository.TagRepository
-	configRepo repository.ConfigRepository
-	registry   *RegistryClient
-	ticker     *time.Ticker
-	stopChan   chan struct{}
+	mu           sync.Mutex
+	current      *SyncJob            // Full sync that is currently running
+	jobs         map[string]*SyncJob // Running and recently finished jobs by ID
+	finishedJobs []string
+	scheduled    map[string]bool // Namespaces with a scheduled sync running
+	retaining    map[uint]bool   // Retention rules with a scheduled run in progress
+	writeMu      sync.Mutex      // Serialises database writes from concurrent workers
+	planMu       sync.Mutex      // Keeps a deletion plan from being started twice
+	imageLocks   *keyedMutex     // Keeps full and targeted syncs of the same image from overlapping
+	queue        *syncQueue      // Targeted syncs queued by registry notifications
+	dockerRepo   repository.DockerRepository
+	imageRepo    repository.ImageRepository
+	tagRepo      repository.TagRepository
+	configRepo   repository.ConfigRepository
+	syncRunRepo  repository.SyncRunRepository
+	scheduleRepo repository.SyncScheduleRepository
+	planRepo     repository.DeletionPlanRepository
+	ruleRepo     repository.RetentionRuleRepository
+	protectRepo  repository.TagProtectionRepository
+	registry     *RegistryClient
+	repoWorkers  int
+	tagWorkers   int
+	runRetention int             // Number of sync runs kept in the history, 0 keeps all
+	baseCtx      context.Context // Context background jobs run in
+	stopChan     chan struct{}
+	stopOnce     sync.Once
 }
 
 func NewSyncService(
@@ -32,159 +52,409 @@ func NewSyncService(
 	imageRepo repository.ImageRepository,
 	tagRepo repository.TagRepository,
 	configRepo repository.ConfigRepository,
+	syncRunRepo repository.SyncRunRepository,
+	scheduleRepo repository.SyncScheduleRepository,
+	planRepo repository.DeletionPlanRepository,
+	ruleRepo repository.RetentionRuleRepository,
+	protectRepo repository.TagProtectionRepository,
 	registryURL string,
 	username string,
 	password string,
+	repoWorkers int,
+	tagWorkers int,
+	runRetention int,
 ) *SyncService {
 	return &SyncService{
-		dockerRepo: dockerRepo,
-		imageRepo:  imageRepo,
-		tagRepo:    tagRepo,
-		configRepo: configRepo,
-		registry:   NewRegistryClient(registryURL, username, password),
-		stopChan:   make(chan struct{}),
+		dockerRepo:   dockerRepo,
+		imageRepo:    imageRepo,
+		tagRepo:      tagRepo,
+		configRepo:   configRepo,
+		syncRunRepo:  syncRunRepo,
+		scheduleRepo: scheduleRepo,
+		planRepo:     planRepo,
+		ruleRepo:     ruleRepo,
+		protectRepo:  protectRepo,
+		scheduled:    make(map[string]bool),
+		retaining:    make(map[uint]bool),
+		registry:     NewRegistryClient(registryURL, username, password),
+		repoWorkers:  repoWorkers,
+		tagWorkers:   tagWorkers,
+		runRetention: runRetention,
+		jobs:         make(map[string]*SyncJob),
+		imageLocks:   newKeyedMutex(),
+		queue:        newSyncQueue(),
+		baseCtx:      context.Background(),
+		stopChan:     make(chan struct{}),
 	}
 }
 
 func (s *SyncService) Start(ctx context.Context) error {
-	// Get sync interval from config
+	s.baseCtx = ctx
+
+	// The global schedule falls back to the sync interval until it gets a
+	// cron expression, default to 5 minutes if not set
 	syncConfig, err := s.configRepo.Get(ctx, "sync_interval")
 	if err != nil {
 		return fmt.Errorf("failed to get sync interval config: %w", err)
 	}
-
-	// Default to 5 minutes if not set
-	interval := 5
-	if syncConfig != nil {
-		if i, err := strconv.Atoi(syncConfig.Value); err == nil {
-			if isValidInterval(i) {
-				interval = i
-			}
-		}
-	} else {
-		// Create default config if it doesn't exist
-		err = s.configRepo.Update(ctx, "sync_interval", "5")
-		if err != nil {
+	if syncConfig == nil {
+		if err := s.configRepo.Update(ctx, "sync_interval", "5"); err != nil {
 			return fmt.Errorf("failed to create default sync interval config: %w", err)
 		}
 	}
 
-	s.ticker = time.NewTicker(time.Duration(interval) * time.Minute)
+	if err := s.ensureGlobalSchedule(ctx); err != nil {
+		return err
+	}
 
-	// Start sync loop
-	go func() {
-		// Perform initial sync
-		if err := s.PerformSync(ctx); err != nil {
-			log.Printf("Initial sync failed: %v", err)
-		}
+	// Run targeted syncs queued by registry notifications
+	go s.processQueue(ctx)
 
-		for {
-			select {
-			case <-s.ticker.C:
-				if err := s.PerformSync(ctx); err != nil {
-					log.Printf("Periodic sync failed: %v", err)
-				}
-			case <-s.stopChan:
-				s.ticker.Stop()
-				return
-			case <-ctx.Done():
-				s.ticker.Stop()
-				return
-			}
-		}
-	}()
+	// Run schedules as they come due, starting with the initial sync
+	go s.runScheduler(ctx)
 
 	return nil
 }
 
-// Add helper function to validate intervals
-func isValidInterval(interval int) bool {
-	validIntervals := []int{5, 15, 30, 60}
-	for _, valid := range validIntervals {
-		if interval == valid {
-			return true
+func (s *SyncService) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// withStop returns a context that is also cancelled when the service is stopped
+func (s *SyncService) withStop(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-s.stopChan:
+			cancel()
+		case <-ctx.Done():
 		}
-	}
-	return false
+	}()
+	return ctx, cancel
 }
 
-// Add method to update sync interval
-func (s *SyncService) UpdateSyncInterval(ctx context.Context, interval int) error {
-	if !isValidInterval(interval) {
-		return fmt.Errorf("invalid sync interval: must be 5, 15, 30, or 60 minutes")
+// PerformSync syncs the whole registry catalog and waits for it to finish.
+// It fails if another full sync is already running.
+func (s *SyncService) PerformSync(ctx context.Context, trigger string) (*SyncResult, error) {
+	job, started := s.beginJob(trigger)
+	if !started {
+		return nil, fmt.Errorf("sync already in progress")
 	}
+	return s.runJob(ctx, job)
+}
 
-	// Update in database
-	err := s.configRepo.Update(ctx, "sync_interval", strconv.Itoa(interval))
-	if err != nil {
-		return fmt.Errorf("failed to update sync interval: %w", err)
+// StartSync starts a full sync in the background and returns its job. If a
+// sync is already running, that job is returned and started is false.
+func (s *SyncService) StartSync(trigger string) (job *SyncJob, started bool) {
+	job, started = s.beginJob(trigger)
+	if started {
+		go func() {
+			if _, err := s.runJob(s.baseCtx, job); err != nil {
+				log.Printf("Sync job %s failed: %v", job.ID, err)
+			}
+		}()
 	}
+	return job, started
+}
 
-	// Update ticker
-	s.ticker.Reset(time.Duration(interval) * time.Minute)
-
-	return nil
+// GetJob returns a running or recently finished sync job, or nil
+func (s *SyncService) GetJob(id string) *SyncJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
 }
 
-func (s *SyncService) Stop() {
-	close(s.stopChan)
+// beginJob registers a new job unless a full sync is already running
+func (s *SyncService) beginJob(trigger string) (*SyncJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil {
+		return s.current, false
+	}
+
+	job := newSyncJob(trigger)
+	s.current = job
+	s.jobs[job.ID] = job
+	return job, true
 }
 
-func (s *SyncService) PerformSync(ctx context.Context) error {
+// runJob performs the sync for a job and keeps it around for a while after it finished
+func (s *SyncService) runJob(ctx context.Context, job *SyncJob) (*SyncResult, error) {
+	result, err := s.performSync(ctx, job)
+	job.finish(result, err)
+
 	s.mu.Lock()
-	if s.isSyncing {
-		s.mu.Unlock()
-		return fmt.Errorf("sync already in progress")
+	defer s.mu.Unlock()
+
+	s.current = nil
+	s.finishedJobs = append(s.finishedJobs, job.ID)
+	if len(s.finishedJobs) > maxFinishedSyncJobs {
+		delete(s.jobs, s.finishedJobs[0])
+		s.finishedJobs = s.finishedJobs[1:]
 	}
-	s.isSyncing = true
-	s.mu.Unlock()
 
-	defer func() {
-		s.mu.Lock()
-		s.isSyncing = false
-		s.mu.Unlock()
-	}()
+	return result, err
+}
+
+// performSync syncs the whole registry catalog and records the run in the sync history
+func (s *SyncService) performSync(ctx context.Context, job *SyncJob) (*SyncResult, error) {
+	ctx, cancel := s.withStop(ctx)
+	defer cancel()
 
 	// Update last sync time
 	now := time.Now()
 	if err := s.configRepo.Update(ctx, "last_sync_time", fmt.Sprintf("%d", now.Unix())); err != nil {
-		return fmt.Errorf("failed to update last sync time: %w", err)
+		return nil, fmt.Errorf("failed to update last sync time: %w", err)
+	}
+
+	filter, err := s.loadSyncFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newSyncRun(job.trigger, s.repoWorkers, s.tagWorkers)
+	run.job = job
+	run.filter = filter
+	run.skipNamespaces = job.skipNamespaces
+	record, err := s.recordRunStart(ctx, run.result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sync run: %w", err)
+	}
+
+	result, err := s.syncCatalog(ctx, run)
+	s.recordRunEnd(ctx, record, result, err)
+
+	return result, err
+}
+
+// syncCatalog walks the registry catalog and syncs every repository in it
+func (s *SyncService) syncCatalog(ctx context.Context, run *syncRun) (*SyncResult, error) {
+	repoGroup := newWorkerGroup(run.repoSem)
+
+	// Walk the registry catalog page by page and hand each repository to a worker
+	err := s.registry.WalkRepositories(ctx, func(repositories []string) error {
+		for _, repoPath := range repositories {
+			// Excluded repositories are not marked as seen, so they are
+			// removed like images that disappeared from the registry
+			if !run.filter.repositoryIncluded(repoPath) {
+				continue
+			}
+			if namespace, _ := utils.SplitRepositoryPath(repoPath); slices.Contains(run.skipNamespaces, namespace) {
+				continue
+			}
+			if err := repoGroup.Go(ctx, func() {
+				s.syncRepositoryWorker(ctx, run, repoPath)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	repoGroup.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return run.finish(), fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	// Only reconcile deletions after the whole catalog was read, otherwise
+	// images we never got to would be treated as gone
+	// Namespaces left out of this run keep their images
+	scope := repository.ImageScope{ExcludeNamespaces: run.skipNamespaces}
+	if err := s.removeStaleImages(ctx, run, scope); err != nil {
+		log.Printf("Error removing stale images: %v", err)
+		run.addError("", "", err)
+	}
+
+	result := run.finish()
+
+	if len(result.Errors) > 0 {
+		log.Printf("Sync finished with %d errors across %d repositories", len(result.Errors), result.Repositories)
+	}
+
+	return result, nil
+}
+
+// syncRepositoryWorker syncs one repository of a catalog walk and records its outcome
+func (s *SyncService) syncRepositoryWorker(ctx context.Context, run *syncRun, repoPath string) {
+	unlock := s.imageLocks.Lock(repoPath)
+	defer unlock()
+
+	if err := s.syncRepository(ctx, run, repoPath); err != nil {
+		log.Printf("Error syncing repository %s: %v", repoPath, err)
+		run.addError(repoPath, "", err)
+	}
+	run.repositoryDone()
+}
+
+// syncRepository syncs an image and all its tags. The caller must hold the
+// image lock for repoPath.
+func (s *SyncService) syncRepository(ctx context.Context, run *syncRun, repoPath string) error {
+	log.Printf("Syncing: %s", repoPath)
+	run.repositoryStarted(repoPath)
+
+	namespace, imageName := utils.SplitRepositoryPath(repoPath)
+
+	// ensureImage creates the image if needed and marks it as present in the
+	// catalog for this run
+	var repo *models.Repository
+	var image *models.Image
+	ensureImage := func() error {
+		if image != nil {
+			return nil
+		}
+		var err error
+		repo, image, err = s.getOrCreateImage(ctx, namespace, imageName, repoPath)
+		if err != nil {
+			return err
+		}
+		if err := s.imageRepo.SetLastSynced(ctx, image.ID, run.result.StartedAt); err != nil {
+			return fmt.Errorf("failed to mark image as synced: %w", err)
+		}
+		return nil
+	}
+
+	// Known images are marked right away so a failing tag list doesn't get
+	// them removed as stale. New images are only created once the registry
+	// listed their tags, so syncing an unknown name leaves nothing behind.
+	stored, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+	if err != nil {
+		return fmt.Errorf("failed to get image: %w", err)
+	}
+	if stored != nil {
+		if err := ensureImage(); err != nil {
+			return err
+		}
+	}
+
+	tagGroup := newWorkerGroup(run.tagSem)
+	registryTags := make(map[string]struct{})
+
+	// Walk the tags of this image page by page and hand each tag to a worker
+	err = s.registry.WalkTags(ctx, repoPath, func(tags []string) error {
+		if err := ensureImage(); err != nil {
+			return err
+		}
+		var included []string
+		for _, tagName := range tags {
+			// Excluded tags are left out of registryTags so stored ones get removed
+			if run.filter.tagIncluded(tagName) {
+				included = append(included, tagName)
+			}
+		}
+
+		run.tagsFound(len(included))
+		for _, tagName := range included {
+			registryTags[tagName] = struct{}{}
+			if err := tagGroup.Go(ctx, func() {
+				err := s.syncTag(ctx, run, repo, image, repoPath, tagName)
+				if errors.Is(err, errTagNotFound) {
+					// Deleted while we were listing, removeStaleTags catches it next run
+					log.Printf("Tag %s in repository %s no longer exists in registry, skipping", tagName, repoPath)
+				} else if err != nil {
+					log.Printf("Error syncing tag %s in repository %s: %v", tagName, repoPath, err)
+					run.addError(repoPath, tagName, err)
+				}
+				run.tagDone()
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tagGroup.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	if err := ensureImage(); err != nil {
+		return err
+	}
+
+	if err := s.removeStaleTags(ctx, run, namespace, imageName, repoPath, registryTags); err != nil {
+		return err
+	}
+
+	// Update repository last sync time
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	repo.LastSynced = time.Now()
+	if err := s.dockerRepo.UpdateRepository(ctx, repo); err != nil {
+		return fmt.Errorf("failed to update repository: %w", err)
 	}
 
-	// Get list of repositories from registry
-	repositories, err := s.registry.ListRepositories(ctx)
+	run.repositorySynced()
+	return nil
+}
+
+// removeStaleTags deletes tags of an image that are no longer in the registry
+// tag list or are excluded by the sync filters
+func (s *SyncService) removeStaleTags(ctx context.Context, run *syncRun, namespace, imageName, repoPath string, registryTags map[string]struct{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tags, err := s.tagRepo.ListTags(ctx, namespace, imageName)
 	if err != nil {
-		return fmt.Errorf("failed to list repositories: %w", err)
+		return fmt.Errorf("failed to list stored tags: %w", err)
 	}
 
-	// Process each repository
-	for _, repoPath := range repositories {
-		if err := s.syncRepository(ctx, repoPath); err != nil {
-			log.Printf("Error syncing repository %s: %v", repoPath, err)
+	for _, tag := range tags {
+		if _, ok := registryTags[tag.Name]; ok {
 			continue
 		}
+		if err := s.tagRepo.RemoveTag(ctx, tag.ID); err != nil {
+			return fmt.Errorf("failed to remove stale tag %s: %w", tag.Name, err)
+		}
+		log.Printf("Removed tag %s:%s, it is no longer in the registry or excluded from sync", repoPath, tag.Name)
+		run.addRemoval(RemovedTag, repoPath+":"+tag.Name)
 	}
 
 	return nil
 }
 
-// Update the syncRepository function to parse namespace and image name correctly
-func (s *SyncService) syncRepository(ctx context.Context, repoPath string) error {
-	log.Printf("Syncing: %s", repoPath)
+// removeStaleImages deletes images within scope that were not seen in the
+// catalog during this run, followed by namespaces that no longer contain any image
+func (s *SyncService) removeStaleImages(ctx context.Context, run *syncRun, scope repository.ImageScope) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
-	// Extract namespace from the full path
-	namespace := "library" // Default namespace like Docker Hub uses
-	imageName := repoPath
-	if strings.Contains(repoPath, "/") {
-		parts := strings.SplitN(repoPath, "/", 2)
-		namespace = parts[0]
-		imageName = parts[1]
+	images, err := s.imageRepo.DeleteStaleImages(ctx, run.result.StartedAt, scope)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale images: %w", err)
+	}
+	for _, image := range images {
+		log.Printf("Removed image %s, it is no longer in the registry or excluded from sync", image.FullName)
+		run.addRemoval(RemovedImage, image.FullName)
 	}
 
+	namespaces, err := s.dockerRepo.DeleteEmptyRepositories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove empty namespaces: %w", err)
+	}
+	for _, name := range namespaces {
+		log.Printf("Removed empty namespace %s", name)
+		run.addRemoval(RemovedRepository, name)
+	}
+
+	return nil
+}
+
+// getOrCreateImage looks up the namespace and image rows for a repository path
+// and creates them if they don't exist yet. The write lock keeps concurrent
+// workers from creating the same namespace twice.
+func (s *SyncService) getOrCreateImage(ctx context.Context, namespace, imageName, repoPath string) (*models.Repository, *models.Image, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	// Get or create repository (namespace)
 	repo, err := s.dockerRepo.GetRepository(ctx, namespace)
 	if err != nil {
-		return fmt.Errorf("failed to get repository namespace: %w", err)
+		return nil, nil, fmt.Errorf("failed to get repository namespace: %w", err)
 	}
 
 	if repo == nil {
@@ -192,14 +462,23 @@ func (s *SyncService) syncRepository(ctx context.Context, repoPath string) error
 			Name: namespace,
 		}
 		if err := s.dockerRepo.CreateRepository(ctx, repo); err != nil {
-			return fmt.Errorf("failed to create repository namespace: %w", err)
+			return nil, nil, fmt.Errorf("failed to create repository namespace: %w", err)
 		}
 	}
 
-	// Get or create image within this repository
-	image, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+	// Images are keyed by their catalog path, the namespace and name follow from it
+	image, err := s.imageRepo.GetImageByPath(ctx, repoPath)
 	if err != nil {
-		return fmt.Errorf("failed to get image: %w", err)
+		return nil, nil, fmt.Errorf("failed to get image: %w", err)
+	}
+
+	if image != nil && (image.RepositoryID != repo.ID || image.Name != imageName) {
+		// Top-level images used to be stored in the "library" namespace
+		image.RepositoryID = repo.ID
+		image.Name = imageName
+		if err := s.imageRepo.UpdateImage(ctx, image); err != nil {
+			return nil, nil, fmt.Errorf("failed to move image %s: %w", repoPath, err)
+		}
 	}
 
 	if image == nil {
@@ -209,167 +488,248 @@ func (s *SyncService) syncRepository(ctx context.Context, repoPath string) error
 			FullName:     repoPath, // Keep the full path in FullName
 		}
 		if err := s.imageRepo.CreateImage(ctx, image); err != nil {
-			return fmt.Errorf("failed to create image: %w", err)
+			return nil, nil, fmt.Errorf("failed to create image: %w", err)
 		}
 	}
 
-	// Get list of tags for this image
-	tags, err := s.registry.ListTags(ctx, repoPath)
+	return repo, image, nil
+}
+
+// Version of what syncTag stores for a tag. Increase it when tags need to be
+// synced again to fill in data older versions didn't record. Version 2 stores
+// the full runtime configuration of the image.
+const tagSyncVersion = 2
+
+// syncTag stores a tag with one platform per image it points to, unless its
+// digest didn't change since the last sync
+func (s *SyncService) syncTag(ctx context.Context, run *syncRun, repo *models.Repository, image *models.Image, repoPath string, tagName string) error {
+	existing, err := s.tagRepo.GetTag(ctx, repo.Name, image.Name, tagName)
 	if err != nil {
-		return fmt.Errorf("failed to list tags: %w", err)
+		return fmt.Errorf("failed to get tag: %w", err)
 	}
 
-	// Process each tag
-	for _, tagName := range tags {
-		if err := s.syncTag(ctx, repo, image, namespace+"/"+imageName, tagName); err != nil {
-			log.Printf("Error syncing tag %s in repository %s: %v", tagName, repoPath, err)
-			continue
+	// Resolve the tag to its current digest with a HEAD request, so unchanged
+	// tags don't need their manifest and config downloaded again. Tags stored
+	// by older versions are synced once more.
+	manifestDigest, err := s.registry.HeadManifest(ctx, repoPath, tagName)
+	if err != nil {
+		if isNotFound(err) {
+			return errTagNotFound
 		}
+		log.Printf("HEAD request for tag %s in repository %s failed, fetching manifest instead: %v", tagName, repoPath, err)
+	} else if manifestDigest != "" && existing != nil && existing.ManifestDigest == manifestDigest &&
+		existing.SyncVersion >= tagSyncVersion {
+		log.Printf("Tag %s in repository %s is unchanged (%s), skipping", tagName, repoPath, manifestDigest)
+		return nil
 	}
 
-	// Update repository last sync time
-	repo.LastSynced = time.Now()
-	if err := s.dockerRepo.UpdateRepository(ctx, repo); err != nil {
-		return fmt.Errorf("failed to update repository: %w", err)
-	}
-
-	return nil
-}
-
-// Update syncTag to handle specific error scenarios
-func (s *SyncService) syncTag(ctx context.Context, repo *models.Repository, image *models.Image, repoPath string, tagName string) error {
 	// Get manifest for this tag
 	manifest, err := s.registry.GetManifest(ctx, repoPath, tagName)
 	if err != nil {
 		// If we get a 404 for the manifest, this might be expected for deleted tags
-		if strings.Contains(err.Error(), "404") {
-			log.Printf("Tag %s in repository %s no longer exists in registry, skipping", tagName, repoPath)
-			return nil
+		if isNotFound(err) {
+			return errTagNotFound
 		}
 		return fmt.Errorf("failed to get manifest: %w", err)
 	}
 
-	// Check if the manifest is a manifest list
-	if manifest.MediaType == "application/vnd.oci.image.index.v1+json" || manifest.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json" {
+	// Fall back to the digest header of the GET response if HEAD didn't provide one
+	if manifestDigest == "" {
+		manifestDigest = manifest.Digest
+	}
 
-		// Iterate through the manifests in the list
-		for _, m := range manifest.Manifests {
-			// Fetch the manifest for this entry
-			platformManifest, err := s.registry.GetManifest(ctx, repoPath, m.Digest)
-			if err != nil {
-				log.Printf("Failed to fetch manifest for digest %s: %v", m.Digest, err)
-				continue
-			}
+	platforms, err := s.fetchPlatforms(ctx, repoPath, tagName, manifestDigest, manifest)
+	if err != nil {
+		return err
+	}
 
-			// Process the platform-specific manifest
-			if err := s.processManifest(ctx, repo, image, repoPath, tagName, platformManifest); err != nil {
-				log.Printf("Failed to process manifest for digest %s: %v", m.Digest, err)
-				continue
-			}
+	previous, err := s.previousRevision(ctx, image, repoPath, tagName, existing, manifestDigest)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tag := existing
+	if tag == nil {
+		tag = &models.Tag{ImageID: image.ID, Name: tagName}
+	}
+
+	// The first platform is the default one the tag shows
+	tag.Digest = platforms[0].Metadata.ConfigDigest
+	tag.ManifestDigest = manifestDigest
+	tag.Metadata = platforms[0].Metadata
+	tag.Metadata.Layers = slices.Clone(platforms[0].Metadata.Layers)
+	tag.Metadata.History = slices.Clone(platforms[0].Metadata.History)
+	tag.Platforms = platforms
+	tag.SyncVersion = tagSyncVersion
+
+	if existing == nil {
+		if err := s.tagRepo.CreateTag(ctx, tag); err != nil {
+			return fmt.Errorf("failed to create tag: %w", err)
 		}
+	} else {
+		if err := s.tagRepo.UpdateTag(ctx, tag); err != nil {
+			return fmt.Errorf("failed to update tag: %w", err)
+		}
+	}
 
-		return nil
+	if previous != nil {
+		if err := s.tagRepo.RecordRevision(ctx, previous); err != nil {
+			return err
+		}
+	}
+	// Compare with the last recorded revision rather than the stored tag, so
+	// a protected tag that was deleted and pushed again is caught too
+	latest, err := s.tagRepo.GetLatestRevision(ctx, image.ID, tagName)
+	if err != nil {
+		return fmt.Errorf("failed to get tag revision: %w", err)
+	}
+	if latest != nil && latest.Digest != manifestDigest {
+		s.recordViolation(ctx, repo.Name, image.Name, tagName, latest.Digest, manifestDigest)
+	}
+	revision := &models.TagRevision{
+		ImageID:   image.ID,
+		TagName:   tagName,
+		Digest:    manifestDigest,
+		MediaType: manifest.MediaType,
+		Manifest:  manifest.Raw,
+		FirstSeen: time.Now(),
+	}
+	if err := s.tagRepo.RecordRevision(ctx, revision); err != nil {
+		return err
 	}
 
-	// Process the single manifest
-	return s.processManifest(ctx, repo, image, repoPath, tagName, manifest)
+	run.tagSynced(existing == nil)
+	return nil
 }
 
-//nolint:gocognit
-func (s *SyncService) processManifest(ctx context.Context, repo *models.Repository, image *models.Image, repoPath string, tagName string, manifest *ManifestResponse) error {
-
-	// Handle OCI manifest list or Docker manifest list
-	if manifest.MediaType == "application/vnd.oci.image.index.v1+json" ||
-		manifest.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json" {
-
-		// Get the first non-attestation manifest for the default platform
-		var targetManifest *struct {
-			MediaType string `json:"mediaType"`
-			Digest    string `json:"digest"`
-			Size      int64  `json:"size"`
-			Platform  struct {
-				Architecture string `json:"architecture"`
-				OS           string `json:"os"`
-			} `json:"platform"`
-		}
-
-		// Find first non-attestation manifest that's not for an unknown platform
-		for _, m := range manifest.Manifests {
-			if !strings.Contains(m.MediaType, "attestation") &&
-				m.Platform.OS != "unknown" &&
-				m.Platform.Architecture != "unknown" {
-				targetManifest = &m
-				break
-			}
+// previousRevision returns the revision to record for the digest a changed
+// tag pointed to before, if none was recorded yet because the tag was stored
+// by an older version. Its manifest is fetched while the registry still has it.
+func (s *SyncService) previousRevision(ctx context.Context, image *models.Image, repoPath, tagName string, existing *models.Tag, manifestDigest string) (*models.TagRevision, error) {
+	if existing == nil || existing.ManifestDigest == "" || existing.ManifestDigest == manifestDigest {
+		return nil, nil
+	}
+
+	current, err := s.tagRepo.GetCurrentRevision(ctx, image.ID, tagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag revision: %w", err)
+	}
+	if current != nil {
+		return nil, nil
+	}
+
+	revision := &models.TagRevision{
+		ImageID:   image.ID,
+		TagName:   tagName,
+		Digest:    existing.ManifestDigest,
+		FirstSeen: existing.UpdatedAt,
+	}
+	if manifest, err := s.registry.GetManifest(ctx, repoPath, existing.ManifestDigest); err == nil {
+		revision.MediaType = manifest.MediaType
+		revision.Manifest = manifest.Raw
+	} else {
+		log.Printf("Previous manifest %s of tag %s in repository %s is gone, recording its digest only: %v",
+			existing.ManifestDigest, tagName, repoPath, err)
+	}
+	return revision, nil
+}
+
+// fetchPlatforms returns the platforms of a tag's manifest. An index has one
+// per platform manifest, leaving out attestations; a single manifest is the
+// only platform. A platform that can't be fetched fails the tag, so it's
+// synced again rather than stored without it.
+func (s *SyncService) fetchPlatforms(ctx context.Context, repoPath, tagName, manifestDigest string, manifest *ManifestResponse) ([]models.TagPlatform, error) {
+	if !manifest.IsIndex() {
+		platform, err := s.fetchPlatform(ctx, repoPath, tagName, manifestDigest, manifest)
+		if err != nil {
+			return nil, err
 		}
+		return []models.TagPlatform{*platform}, nil
+	}
 
-		if targetManifest == nil {
-			log.Printf("No valid platform manifest found, trying first non-attestation manifest")
-			// Fallback to first non-attestation manifest
-			for _, m := range manifest.Manifests {
-				if !strings.Contains(m.MediaType, "attestation") {
-					targetManifest = &m
-					break
-				}
-			}
+	var platforms []models.TagPlatform
+	for _, m := range manifest.Manifests {
+		if strings.Contains(m.MediaType, "attestation") ||
+			m.Annotations["vnd.docker.reference.type"] == "attestation-manifest" ||
+			m.Platform.OS == "unknown" || m.Platform.Architecture == "unknown" {
+			continue
 		}
 
-		if targetManifest == nil {
-			return fmt.Errorf("no valid manifest found in list")
+		platformManifest, err := s.registry.GetManifest(ctx, repoPath, m.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch manifest %s: %w", m.Digest, err)
+		}
+		if platformManifest.IsIndex() {
+			log.Printf("Skipping nested index %s of tag %s in repository %s", m.Digest, tagName, repoPath)
+			continue
 		}
 
-		// Fetch the actual manifest using the digest
-		actualManifest, err := s.registry.GetManifest(ctx, repoPath, targetManifest.Digest)
+		platform, err := s.fetchPlatform(ctx, repoPath, tagName, cmp.Or(platformManifest.Digest, m.Digest), platformManifest)
 		if err != nil {
-			return fmt.Errorf("failed to get actual manifest: %w", err)
+			return nil, fmt.Errorf("failed to process manifest %s: %w", m.Digest, err)
 		}
 
-		// Set the platform info from the index
-		actualManifest.Platform = targetManifest.Platform
+		platform.Metadata.IndexDigest = manifestDigest
+		if platform.Metadata.Description == "" {
+			platform.Metadata.Description = utils.ExtractDescription(nil, m.Annotations, manifest.Annotations)
+		}
 
-		// Process this actual manifest
-		return s.processManifest(ctx, repo, image, repoPath, tagName, actualManifest)
+		// The index describes the platform, the config may not
+		if m.Platform.OS != "" {
+			platform.OS = m.Platform.OS
+			platform.Architecture = m.Platform.Architecture
+			platform.Variant = m.Platform.Variant
+			platform.OSVersion = m.Platform.OSVersion
+		}
+		platforms = append(platforms, *platform)
 	}
 
-	// Check if the manifest actually has a config
-	if manifest.Config.Digest == "" {
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no valid manifest found in list")
+	}
+	return platforms, nil
+}
 
-		// Create a minimal tag record
-		tag := &models.Tag{
-			ImageID: image.ID,
-			Name:    tagName,
-			Digest:  "", // Set digest to empty string
-		}
+// fetchPlatform builds the platform of a single image manifest from its config and layers
+func (s *SyncService) fetchPlatform(ctx context.Context, repoPath, tagName, manifestDigest string, manifest *ManifestResponse) (*models.TagPlatform, error) {
+	platform := &models.TagPlatform{
+		ManifestDigest: manifestDigest,
+		Metadata: models.TagMetadata{
+			ConfigDigest:  manifest.Config.Digest,
+			ContentDigest: manifestDigest,
+			IsOCI:         manifest.IsOCI(),
+			Description:   utils.ExtractDescription(nil, manifest.Annotations),
+		},
+	}
+	for _, layer := range manifest.Layers {
+		platform.Size += layer.Size
+		platform.Metadata.Layers = append(platform.Metadata.Layers, models.ImageLayer{
+			Size:   layer.Size,
+			Digest: layer.Digest,
+		})
+	}
 
-		if err := s.tagRepo.CreateTag(ctx, tag); err != nil {
-			return fmt.Errorf("failed to create minimal tag: %w", err)
-		}
+	// The image size is what a pull downloads, the compressed layers
+	platform.Metadata.TotalSize = platform.Size
 
-		return nil
+	// Check if the manifest actually has a config
+	if manifest.Config.Digest == "" {
+		return platform, nil
 	}
 
 	// Get config for this image
 	config, err := s.registry.GetConfig(ctx, repoPath, manifest.Config.Digest)
 	if err != nil {
 		// If we get a 404 for the config, this might be a schema v1 image
-		if strings.Contains(err.Error(), "404") {
+		if isNotFound(err) {
 			log.Printf("Config %s for tag %s in repository %s not found, might be schema v1",
 				manifest.Config.Digest, tagName, repoPath)
-
-			// For schema v1, we might want to create a minimal tag record
-			tag := &models.Tag{
-				ImageID: image.ID,
-				Name:    tagName,
-				Digest:  manifest.Config.Digest,
-			}
-
-			if err := s.tagRepo.CreateTag(ctx, tag); err != nil {
-				return fmt.Errorf("failed to create minimal tag: %w", err)
-			}
-
-			return nil
+			return platform, nil
 		}
-		return fmt.Errorf("failed to get config: %w", err)
+		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
 	// Convert exposed ports to string
@@ -387,117 +747,79 @@ func (s *SyncService) processManifest(ctx context.Context, repo *models.Reposito
 		entrypoint = strings.Join(config.Config.Entrypoint, " ")
 	}
 
-	dockerFileContent := utils.ExtractDockerfileFromHistory(config.History)
-
-	// Extract author from config
-	author := utils.ExtractAuthorFromLabels(config.Config.Labels, config.Author)
-
-	// Update tag reference to be against the image
-	tag, err := s.tagRepo.GetTag(ctx, repo.Name, image.Name, tagName)
-	if err != nil {
-		return fmt.Errorf("failed to get tag: %w", err)
+	platform.OS = config.OS
+	platform.Architecture = config.Architecture
+	platform.Variant = config.Variant
+	platform.OSVersion = config.OSVersion
+
+	metadata := &platform.Metadata
+	metadata.Created = config.Created
+	metadata.OS = config.OS
+	metadata.Architecture = config.Architecture
+	metadata.Author = utils.ExtractAuthorFromLabels(config.Config.Labels, config.Author)
+	metadata.WorkDir = config.Config.WorkingDir
+	metadata.Command = cmd
+	metadata.Entrypoint = entrypoint
+	metadata.Description = utils.ExtractDescription(config.Config.Labels, manifest.Annotations)
+	metadata.Env = config.Config.Env
+	metadata.User = config.Config.User
+	metadata.Volumes = slices.Sorted(maps.Keys(config.Config.Volumes))
+	metadata.StopSignal = config.Config.StopSignal
+	metadata.Shell = config.Config.Shell
+	metadata.OnBuild = config.Config.OnBuild
+	metadata.Labels = config.Config.Labels
+	if hc := config.Config.Healthcheck; hc != nil {
+		metadata.Healthcheck = &models.Healthcheck{
+			Test:          hc.Test,
+			Interval:      hc.Interval,
+			Timeout:       hc.Timeout,
+			StartPeriod:   hc.StartPeriod,
+			StartInterval: hc.StartInterval,
+			Retries:       hc.Retries,
+		}
 	}
+	metadata.ExposedPorts = strings.Join(exposedPorts, ",")
+	metadata.DockerFile = utils.ExtractDockerfileFromHistory(config.History)
 
-	if tag == nil {
-		// Create new tag logic remains the same
-		tag = &models.Tag{
-			ImageID: image.ID,
-			Name:    tagName,
-			Digest:  manifest.Config.Digest,
-			Metadata: models.TagMetadata{
-				Created:      config.Created,
-				OS:           config.OS,
-				Architecture: config.Architecture,
-				Author:       author,
-				WorkDir:      config.Config.WorkingDir,
-				Command:      cmd,
-				Entrypoint:   entrypoint,
-				TotalSize:    manifest.Config.Size,
-				ExposedPorts: strings.Join(exposedPorts, ","),
-				DockerFile:   dockerFileContent,
-			},
-		}
-
-		// Add layers
-		for _, layer := range manifest.Layers {
-			tag.Metadata.Layers = append(tag.Metadata.Layers, models.ImageLayer{
-				Size:   layer.Size,
-				Digest: layer.Digest,
-			})
-		}
+	metadata.History = buildHistory(config, manifest)
 
-		if err := s.tagRepo.CreateTag(ctx, tag); err != nil {
-			log.Printf("Failed to create tag: %v", err)
-			return fmt.Errorf("failed to create tag: %w", err)
-		}
-	} else {
-		// Update existing tag if anything has changed
-		needsUpdate := false
-
-		// Check if basic metadata needs updating
-		if tag.Digest != manifest.Config.Digest {
-			needsUpdate = true
-			tag.Digest = manifest.Config.Digest
-		}
-
-		// Always update metadata to ensure we have the latest values
-		newMetadata := models.TagMetadata{
-			Created:      config.Created,
-			OS:           config.OS,
-			Architecture: config.Architecture,
-			Author:       author, // This should now be correctly set
-			WorkDir:      config.Config.WorkingDir,
-			Command:      cmd,
-			Entrypoint:   entrypoint,
-			TotalSize:    manifest.Config.Size,
-			ExposedPorts: strings.Join(exposedPorts, ","),
-			DockerFile:   dockerFileContent,
-		}
-
-		// If metadata exists, check if it needs updating
-		if tag.Metadata.ID != 0 {
-			if tag.Metadata.Author != newMetadata.Author ||
-				tag.Metadata.DockerFile != newMetadata.DockerFile ||
-				tag.Metadata.Command != newMetadata.Command ||
-				tag.Metadata.Entrypoint != newMetadata.Entrypoint ||
-				tag.Metadata.WorkDir != newMetadata.WorkDir ||
-				tag.Metadata.OS != newMetadata.OS ||
-				tag.Metadata.Architecture != newMetadata.Architecture {
-				needsUpdate = true
-				tag.Metadata = newMetadata
-				tag.Metadata.TagID = tag.ID // Preserve the relationship
-			}
-		} else {
-			// No existing metadata, create new
-			needsUpdate = true
-			tag.Metadata = newMetadata
-			tag.Metadata.TagID = tag.ID
-		}
-
-		// Update layers if needed
-		newLayers := make([]models.ImageLayer, len(manifest.Layers))
-		for i, layer := range manifest.Layers {
-			newLayers[i] = models.ImageLayer{
-				Size:   layer.Size,
-				Digest: layer.Digest,
-			}
-		}
+	return platform, nil
+}
 
-		if !reflect.DeepEqual(tag.Metadata.Layers, newLayers) {
-			needsUpdate = true
-			tag.Metadata.Layers = newLayers
+// buildHistory turns the history of an image config into build steps. Steps
+// that created a layer are paired with the manifest's layers in order, unless
+// their number doesn't match and the pairing would be wrong.
+func buildHistory(config *ConfigResponse, manifest *ManifestResponse) []models.HistoryEntry {
+	layerSteps := 0
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			layerSteps++
 		}
-
-		if needsUpdate {
-			if err := s.tagRepo.UpdateTag(ctx, tag); err != nil {
-				return fmt.Errorf("failed to update tag: %w", err)
-			}
-		} else {
-			log.Printf("No updates needed for tag %s", tag.Name)
+	}
+	pairLayers := layerSteps == len(manifest.Layers)
+
+	history := make([]models.HistoryEntry, 0, len(config.History))
+	layer := 0
+	for i, h := range config.History {
+		instruction, command := utils.ParseHistoryInstruction(h.CreatedBy)
+		entry := models.HistoryEntry{
+			Step:        i,
+			Created:     h.Created,
+			CreatedBy:   h.CreatedBy,
+			Instruction: instruction,
+			Command:     command,
+			Author:      h.Author,
+			Comment:     h.Comment,
+			EmptyLayer:  h.EmptyLayer,
 		}
+		if !h.EmptyLayer && pairLayers {
+			entry.LayerDigest = manifest.Layers[layer].Digest
+			entry.LayerSize = manifest.Layers[layer].Size
+			layer++
+		}
+		history = append(history, entry)
 	}
-
-	return nil
+	return history
 }
 
 func (s *SyncService) GetLastSyncTime(ctx context.Context) (*time.Time, error) {
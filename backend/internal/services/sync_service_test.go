@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+	gormrepo "github.com/ofkm/svelocker-ui/backend/internal/repository/gorm"
+)
+
+// newTestSyncService returns a sync service for the registry at registryURL
+// with a database of its own
+func newTestSyncService(t *testing.T, registryURL string) *SyncService {
+	t.Helper()
+	db := openTestDB(t,
+		&models.AppConfig{}, &models.Repository{}, &models.Image{}, &models.Tag{},
+		&models.TagMetadata{}, &models.TagPlatform{}, &models.TagRevision{},
+		&models.ImageLayer{}, &models.HistoryEntry{}, &models.SyncRun{},
+		&models.SyncRunError{}, &models.SyncSchedule{}, &models.DeletionPlan{},
+		&models.DeletionPlanItem{}, &models.RetentionRule{}, &models.TagProtection{},
+		&models.ProtectionViolation{},
+	)
+	return NewSyncService(
+		gormrepo.NewDockerRepository(db),
+		gormrepo.NewImageRepository(db),
+		gormrepo.NewTagRepository(db),
+		gormrepo.NewConfigRepository(db),
+		gormrepo.NewSyncRunRepository(db),
+		gormrepo.NewSyncScheduleRepository(db),
+		gormrepo.NewDeletionPlanRepository(db),
+		gormrepo.NewRetentionRuleRepository(db),
+		gormrepo.NewTagProtectionRepository(db),
+		registryURL, "", "", 2, 2, 10,
+	)
+}
+
+// syncAll runs a full sync and fails the test if it doesn't succeed
+func syncAll(t *testing.T, s *SyncService) {
+	t.Helper()
+	if _, err := s.PerformSync(context.Background(), models.SyncTriggerManual); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+}
+
+func TestFetchPlatformsOfIndex(t *testing.T) {
+	registry := newFakeRegistry(t)
+	index := registry.pushIndex("team/app", "multi", "linux/amd64", "linux/arm64")
+	s := &SyncService{registry: registry.client()}
+	ctx := context.Background()
+
+	manifest, err := s.registry.GetManifest(ctx, "team/app", index)
+	if err != nil {
+		t.Fatalf("failed to get index: %v", err)
+	}
+	platforms, err := s.fetchPlatforms(ctx, "team/app", "multi", index, manifest)
+	if err != nil {
+		t.Fatalf("fetchPlatforms failed: %v", err)
+	}
+	if len(platforms) != 2 || platforms[0].Architecture != "amd64" || platforms[1].Architecture != "arm64" {
+		t.Fatalf("got platforms %+v, want amd64 and arm64", platforms)
+	}
+	for _, platform := range platforms {
+		if platform.Metadata.IndexDigest != index {
+			t.Errorf("%s platform has index digest %q, want %q", platform.Architecture, platform.Metadata.IndexDigest, index)
+		}
+	}
+
+	// A platform manifest the registry can't serve fails the whole tag,
+	// rather than storing it without that platform
+	registry.mu.Lock()
+	delete(registry.manifests, "team/app@"+manifest.Manifests[1].Digest)
+	registry.mu.Unlock()
+	if platforms, err := s.fetchPlatforms(ctx, "team/app", "multi", index, manifest); err == nil {
+		t.Errorf("fetchPlatforms = %+v with a missing platform manifest, want an error", platforms)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/repository"
+	"github.com/ofkm/svelocker-ui/backend/internal/utils"
+)
+
+// ErrNotInRegistry is returned by targeted syncs when the namespace, image or
+// tag does not exist in the registry
+var ErrNotInRegistry = errors.New("not found in registry")
+
+// errTagNotFound is returned by syncTag when the registry no longer has the tag
+var errTagNotFound = errors.New("tag no longer exists in registry")
+
+// SyncNamespace syncs every image of a namespace that is in the registry
+// catalog and removes the namespace's images that disappeared from it.
+func (s *SyncService) SyncNamespace(ctx context.Context, trigger, namespace string) (*SyncResult, error) {
+	return s.runTargeted(ctx, trigger, namespace, nil, func(ctx context.Context, run *syncRun) error {
+		repoGroup := newWorkerGroup(run.repoSem)
+		found, excluded := 0, 0
+
+		err := s.registry.WalkRepositories(ctx, func(repositories []string) error {
+			for _, repoPath := range repositories {
+				if ns, _ := utils.SplitRepositoryPath(repoPath); ns != namespace {
+					continue
+				}
+				found++
+				if !run.filter.repositoryIncluded(repoPath) {
+					excluded++
+					continue
+				}
+				if err := repoGroup.Go(ctx, func() {
+					s.syncRepositoryWorker(ctx, run, repoPath)
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		repoGroup.Wait()
+
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list repositories: %w", err)
+		}
+
+		scope := repository.ImageScope{Namespace: namespace}
+		if err := s.removeStaleImages(ctx, run, scope); err != nil {
+			return err
+		}
+
+		if found == 0 {
+			return fmt.Errorf("namespace %s: %w", namespace, ErrNotInRegistry)
+		}
+		if found == excluded {
+			return fmt.Errorf("namespace %s: %w", namespace, ErrExcludedFromSync)
+		}
+		return nil
+	})
+}
+
+// SyncImage syncs all tags of a single image. An image that no longer exists
+// in the registry is removed from the database.
+func (s *SyncService) SyncImage(ctx context.Context, trigger, namespace, imageName string) (*SyncResult, error) {
+	repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	included := func(f *syncFilter) bool {
+		return f.repositoryIncluded(repoPath)
+	}
+
+	return s.runTargeted(ctx, trigger, repoPath, included, func(ctx context.Context, run *syncRun) error {
+		unlock := s.imageLocks.Lock(repoPath)
+		defer unlock()
+
+		err := s.syncRepository(ctx, run, repoPath)
+		if isNotFound(err) {
+			if err := s.removeImage(ctx, run, namespace, imageName, repoPath); err != nil {
+				return err
+			}
+			return fmt.Errorf("image %s: %w", repoPath, ErrNotInRegistry)
+		}
+		return err
+	})
+}
+
+// SyncTag syncs a single tag of an image. A tag that no longer exists in the
+// registry is removed from the database.
+func (s *SyncService) SyncTag(ctx context.Context, trigger, namespace, imageName, tagName string) (*SyncResult, error) {
+	repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	included := func(f *syncFilter) bool {
+		return f.repositoryIncluded(repoPath) && f.tagIncluded(tagName)
+	}
+
+	return s.runTargeted(ctx, trigger, repoPath+":"+tagName, included, func(ctx context.Context, run *syncRun) error {
+		unlock := s.imageLocks.Lock(repoPath)
+		defer unlock()
+
+		run.repositoryStarted(repoPath)
+
+		// Don't create rows for an image we don't know yet unless the tag exists
+		stored, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+		if err != nil {
+			return fmt.Errorf("failed to get image: %w", err)
+		}
+		if stored == nil {
+			if _, err := s.registry.HeadManifest(ctx, repoPath, tagName); isNotFound(err) {
+				return fmt.Errorf("tag %s:%s: %w", repoPath, tagName, ErrNotInRegistry)
+			}
+		}
+
+		repo, image, err := s.getOrCreateImage(ctx, namespace, imageName, repoPath)
+		if err != nil {
+			return err
+		}
+
+		run.tagsFound(1)
+		err = s.syncTag(ctx, run, repo, image, repoPath, tagName)
+		run.tagDone()
+
+		if errors.Is(err, errTagNotFound) {
+			if err := s.removeTag(ctx, run, namespace, imageName, repoPath, tagName); err != nil {
+				return err
+			}
+			return fmt.Errorf("tag %s:%s: %w", repoPath, tagName, ErrNotInRegistry)
+		}
+		if err != nil {
+			run.addError(repoPath, tagName, err)
+			return nil
+		}
+
+		run.repositorySynced()
+		return nil
+	})
+}
+
+// runTargeted runs a sync of part of the registry and records it in the sync
+// history. Scopes the sync filters exclude are rejected before anything is
+// recorded; included may be nil when fn checks the filters itself.
+func (s *SyncService) runTargeted(ctx context.Context, trigger, scope string, included func(f *syncFilter) bool, fn func(ctx context.Context, run *syncRun) error) (*SyncResult, error) {
+	ctx, cancel := s.withStop(ctx)
+	defer cancel()
+
+	filter, err := s.loadSyncFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if included != nil && !included(filter) {
+		return nil, fmt.Errorf("%s: %w", scope, ErrExcludedFromSync)
+	}
+
+	run := newSyncRun(trigger, s.repoWorkers, s.tagWorkers)
+	run.result.Scope = scope
+	run.filter = filter
+	record, err := s.recordRunStart(ctx, run.result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sync run: %w", err)
+	}
+
+	err = fn(ctx, run)
+	result := run.finish()
+	s.recordRunEnd(ctx, record, result, err)
+
+	return result, err
+}
+
+// repositoryPath returns the registry path of an image. Stored images keep
+// the exact catalog path they were synced from, for others it follows from
+// the namespace and name the same way SplitRepositoryPath splits it.
+func (s *SyncService) repositoryPath(ctx context.Context, namespace, imageName string) (string, error) {
+	image, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image: %w", err)
+	}
+	if image != nil && image.FullName != "" {
+		return image.FullName, nil
+	}
+	return utils.JoinRepositoryPath(namespace, imageName), nil
+}
+
+// removeTag deletes a single stored tag that no longer exists in the registry
+func (s *SyncService) removeTag(ctx context.Context, run *syncRun, namespace, imageName, repoPath, tagName string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tag, err := s.tagRepo.GetTag(ctx, namespace, imageName, tagName)
+	if err != nil {
+		return fmt.Errorf("failed to get tag: %w", err)
+	}
+	if tag == nil {
+		return nil
+	}
+
+	if err := s.tagRepo.RemoveTag(ctx, tag.ID); err != nil {
+		return fmt.Errorf("failed to remove tag %s: %w", tagName, err)
+	}
+	log.Printf("Removed tag %s:%s, it no longer exists in the registry", repoPath, tagName)
+	run.addRemoval(RemovedTag, repoPath+":"+tagName)
+	return nil
+}
+
+// removeImage deletes a single stored image that no longer exists in the
+// registry, and its namespace once that is empty
+func (s *SyncService) removeImage(ctx context.Context, run *syncRun, namespace, imageName, repoPath string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	image, err := s.imageRepo.GetImage(ctx, namespace, imageName)
+	if err != nil {
+		return fmt.Errorf("failed to get image: %w", err)
+	}
+	if image == nil {
+		return nil
+	}
+
+	if err := s.imageRepo.RemoveImage(ctx, image.ID); err != nil {
+		return fmt.Errorf("failed to remove image %s: %w", repoPath, err)
+	}
+	log.Printf("Removed image %s, it no longer exists in the registry", repoPath)
+	run.addRemoval(RemovedImage, repoPath)
+
+	namespaces, err := s.dockerRepo.DeleteEmptyRepositories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove empty namespaces: %w", err)
+	}
+	for _, name := range namespaces {
+		log.Printf("Removed empty namespace %s", name)
+		run.addRemoval(RemovedRepository, name)
+	}
+	return nil
+}
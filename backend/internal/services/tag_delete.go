@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SharedDigestError is returned when deleting a tag would also delete other
+// tags, because the registry deletes manifests and not tags
+type SharedDigestError struct {
+	Digest string
+	Tags   []string
+}
+
+func (e *SharedDigestError) Error() string {
+	return fmt.Sprintf("manifest %s is also tagged as %s, deleting it removes those tags too", e.Digest, strings.Join(e.Tags, ", "))
+}
+
+// TagDeletion describes a manifest deleted from the registry and the tags
+// that went with it
+type TagDeletion struct {
+	Digest      string   `json:"digest"`
+	DeletedTags []string `json:"deletedTags"`
+}
+
+// DeleteTag deletes the manifest a tag points to from the registry. If other
+// tags point to the same manifest a *SharedDigestError is returned, unless
+// confirm is set and they are deleted as well. A *ProtectedTagError is returned
+// if any of them are protected. The database is only updated once the registry
+// deleted the manifest.
+func (s *SyncService) DeleteTag(ctx context.Context, namespace, imageName, tagName string, confirm bool) (*TagDeletion, error) {
+	repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTagProtection(ctx, namespace, imageName, []string{tagName}); err != nil {
+		return nil, err
+	}
+
+	unlock := s.imageLocks.Lock(repoPath)
+	defer unlock()
+
+	digest, err := s.registry.HeadManifest(ctx, repoPath, tagName)
+	if err != nil {
+		if isNotFound(err) {
+			if err := s.forgetTags(ctx, namespace, imageName, []string{tagName}); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("tag %s:%s: %w", repoPath, tagName, ErrNotInRegistry)
+		}
+		return nil, fmt.Errorf("failed to resolve tag %s: %w", tagName, err)
+	}
+	// Deleting by an empty digest would hit the manifests endpoint itself
+	if digest == "" {
+		return nil, fmt.Errorf("failed to resolve tag %s: the registry didn't return its digest", tagName)
+	}
+
+	shared, err := s.tagsWithDigest(ctx, repoPath, digest, tagName)
+	if err != nil {
+		return nil, err
+	}
+	// Confirming can't delete the manifest if it would take a protected tag with it
+	if err := s.checkTagProtection(ctx, namespace, imageName, shared); err != nil {
+		return nil, err
+	}
+	if len(shared) > 0 && !confirm {
+		return nil, &SharedDigestError{Digest: digest, Tags: shared}
+	}
+
+	if err := s.registry.DeleteManifest(ctx, repoPath, digest); err != nil {
+		return nil, fmt.Errorf("failed to delete manifest %s: %w", digest, err)
+	}
+
+	deleted := append([]string{tagName}, shared...)
+	log.Printf("Deleted manifest %s from %s, untagging %s", digest, repoPath, strings.Join(deleted, ", "))
+
+	if err := s.forgetTags(ctx, namespace, imageName, deleted); err != nil {
+		return nil, err
+	}
+	return &TagDeletion{Digest: digest, DeletedTags: deleted}, nil
+}
+
+// tagsWithDigest returns the tags of a repository other than except that
+// point to a manifest digest, as the registry sees them right now
+func (s *SyncService) tagsWithDigest(ctx context.Context, repoPath, digest, except string) ([]string, error) {
+	tagsByDigest, err := s.resolveTags(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(tagsByDigest[digest], func(tag string) bool {
+		return tag == except
+	}), nil
+}
+
+// resolveTags groups the tags of a repository by the manifest digest they
+// point to. Tags deleted while resolving are left out.
+func (s *SyncService) resolveTags(ctx context.Context, repoPath string) (map[string][]string, error) {
+	var (
+		mu           sync.Mutex
+		tagsByDigest = make(map[string][]string)
+		headErr      error
+	)
+	group := newWorkerGroup(newSemaphore(s.tagWorkers))
+
+	err := s.registry.WalkTags(ctx, repoPath, func(tags []string) error {
+		for _, tag := range tags {
+			if err := group.Go(ctx, func() {
+				digest, err := s.registry.HeadManifest(ctx, repoPath, tag)
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil && digest != "":
+					tagsByDigest[digest] = append(tagsByDigest[digest], tag)
+				case err == nil:
+					headErr = fmt.Errorf("failed to resolve tag %s: the registry didn't return its digest", tag)
+				case !isNotFound(err):
+					headErr = fmt.Errorf("failed to resolve tag %s: %w", tag, err)
+				}
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	group.Wait()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if headErr != nil {
+		return nil, headErr
+	}
+
+	for _, tags := range tagsByDigest {
+		slices.Sort(tags)
+	}
+	return tagsByDigest, nil
+}
+
+// forgetTags removes tags the registry no longer has from the database
+func (s *SyncService) forgetTags(ctx context.Context, namespace, imageName string, tagNames []string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for _, tagName := range tagNames {
+		tag, err := s.tagRepo.GetTag(ctx, namespace, imageName, tagName)
+		if err != nil {
+			return fmt.Errorf("failed to get tag: %w", err)
+		}
+		if tag == nil {
+			continue
+		}
+		if err := s.tagRepo.RemoveTag(ctx, tag.ID); err != nil {
+			return fmt.Errorf("failed to remove tag %s: %w", tagName, err)
+		}
+	}
+	return nil
+}
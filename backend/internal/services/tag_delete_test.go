@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestDeleteTagConfirmsSharedDigests(t *testing.T) {
+	registry := newFakeRegistry(t)
+	digest := registry.pushImage("team/app", "v1", `{"os":"linux"}`, "layer")
+	registry.pushImage("team/app", "latest", `{"os":"linux"}`, "layer")
+	registry.pushImage("team/app", "v2", `{"os":"linux","v":2}`, "layer")
+	s := newTestSyncService(t, registry.server.URL)
+	syncAll(t, s)
+	ctx := context.Background()
+
+	// latest goes with v1, so it has to be confirmed
+	_, err := s.DeleteTag(ctx, "team", "app", "v1", false)
+	var shared *SharedDigestError
+	if !errors.As(err, &shared) || shared.Digest != digest || !slices.Equal(shared.Tags, []string{"latest"}) {
+		t.Fatalf("DeleteTag = %v, want a shared digest error naming latest", err)
+	}
+	if !registry.hasManifest("team/app", digest) {
+		t.Fatal("manifest was deleted without confirmation")
+	}
+
+	deletion, err := s.DeleteTag(ctx, "team", "app", "v1", true)
+	if err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	if deletion.Digest != digest || !slices.Equal(deletion.DeletedTags, []string{"v1", "latest"}) {
+		t.Errorf("DeleteTag = %+v, want v1 and latest deleted", deletion)
+	}
+	if registry.hasManifest("team/app", digest) {
+		t.Error("manifest is still in the registry")
+	}
+	for tagName, want := range map[string]bool{"v1": false, "latest": false, "v2": true} {
+		if tag, err := s.tagRepo.GetTag(ctx, "team", "app", tagName); err != nil || (tag != nil) != want {
+			t.Errorf("tag %s stored = %v (%v), want %v", tagName, tag != nil, err, want)
+		}
+	}
+}
+
+func TestDeleteTagForgetsTagsGoneFromTheRegistry(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.pushImage("team/app", "v1", `{"os":"linux"}`, "layer")
+	s := newTestSyncService(t, registry.server.URL)
+	syncAll(t, s)
+	ctx := context.Background()
+
+	registry.mu.Lock()
+	delete(registry.tags["team/app"], "v1")
+	registry.mu.Unlock()
+
+	if _, err := s.DeleteTag(ctx, "team", "app", "v1", false); !errors.Is(err, ErrNotInRegistry) {
+		t.Errorf("DeleteTag = %v, want ErrNotInRegistry", err)
+	}
+	if tag, err := s.tagRepo.GetTag(ctx, "team", "app", "v1"); err != nil || tag != nil {
+		t.Errorf("tag is still stored: %+v, %v", tag, err)
+	}
+}
+
+func TestDeleteTagWithoutRegistryDeletes(t *testing.T) {
+	registry := newFakeRegistry(t)
+	digest := registry.pushImage("team/app", "v1", `{"os":"linux"}`, "layer")
+	s := newTestSyncService(t, registry.server.URL)
+	syncAll(t, s)
+	ctx := context.Background()
+
+	registry.mu.Lock()
+	registry.deleteStatus = http.StatusMethodNotAllowed
+	registry.mu.Unlock()
+
+	if _, err := s.DeleteTag(ctx, "team", "app", "v1", false); !errors.Is(err, ErrDeleteUnsupported) {
+		t.Errorf("DeleteTag = %v, want ErrDeleteUnsupported", err)
+	}
+	// Nothing was deleted, so the tag stays
+	if !registry.hasManifest("team/app", digest) {
+		t.Error("manifest is gone from the registry")
+	}
+	if tag, err := s.tagRepo.GetTag(ctx, "team", "app", "v1"); err != nil || tag == nil {
+		t.Errorf("tag was forgotten: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// ErrInvalidTagProtection is returned for protections without a namespace,
+// with a pattern that doesn't compile or that already exist
+var ErrInvalidTagProtection = errors.New("invalid tag protection")
+
+// ErrTagProtectionNotFound is returned for tag protections that don't exist
+var ErrTagProtectionNotFound = errors.New("tag protection not found")
+
+// ProtectedTagError is returned when a delete or promotion would remove or
+// overwrite protected tags. Nothing is changed then.
+type ProtectedTagError struct {
+	Tags []string // References like "namespace/image:tag"
+}
+
+func (e *ProtectedTagError) Error() string {
+	return fmt.Sprintf("protected tags can't be deleted or overwritten: %s", strings.Join(e.Tags, ", "))
+}
+
+// ListTagProtections returns the tag protections of a namespace, of all
+// namespaces if it is empty
+func (s *SyncService) ListTagProtections(ctx context.Context, namespace string) ([]models.TagProtection, error) {
+	return s.protectRepo.ListProtections(ctx, namespace)
+}
+
+// CreateTagProtection protects the tags of a namespace whose whole name
+// matches a regular expression
+func (s *SyncService) CreateTagProtection(ctx context.Context, namespace, pattern string) (*models.TagProtection, error) {
+	if namespace == "" || pattern == "" {
+		return nil, fmt.Errorf("%w: a namespace and a pattern are required", ErrInvalidTagProtection)
+	}
+	if _, err := compileProtection(pattern); err != nil {
+		return nil, fmt.Errorf("%w: invalid pattern: %v", ErrInvalidTagProtection, err)
+	}
+
+	existing, err := s.protectRepo.FindProtection(ctx, namespace, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tag protection: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: namespace %s already protects %q", ErrInvalidTagProtection, namespace, pattern)
+	}
+
+	protection := &models.TagProtection{Namespace: namespace, Pattern: pattern}
+	if err := s.protectRepo.CreateProtection(ctx, protection); err != nil {
+		return nil, fmt.Errorf("failed to create tag protection: %w", err)
+	}
+	return protection, nil
+}
+
+// DeleteTagProtection removes a tag protection. The violations recorded for it are kept.
+func (s *SyncService) DeleteTagProtection(ctx context.Context, id uint) error {
+	protection, err := s.protectRepo.GetProtection(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get tag protection: %w", err)
+	}
+	if protection == nil {
+		return ErrTagProtectionNotFound
+	}
+	if err := s.protectRepo.DeleteProtection(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete tag protection: %w", err)
+	}
+	return nil
+}
+
+// ListProtectionViolations returns a page of the overwrites of protected tags
+// the sync found in a namespace, of all namespaces if it is empty
+func (s *SyncService) ListProtectionViolations(ctx context.Context, namespace string, page, limit int) ([]models.ProtectionViolation, int64, error) {
+	return s.protectRepo.ListViolations(ctx, namespace, page, limit)
+}
+
+// compileProtection compiles a protection pattern so it has to match the
+// whole tag name, "v1" then doesn't protect "v10"
+func compileProtection(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// tagProtection are the compiled protections of a namespace
+type tagProtection struct {
+	namespace string
+	patterns  []string
+	compiled  []*regexp.Regexp
+}
+
+// loadTagProtection returns the protections of a namespace. Patterns that no
+// longer compile are skipped with a warning.
+func (s *SyncService) loadTagProtection(ctx context.Context, namespace string) (*tagProtection, error) {
+	protections, err := s.protectRepo.ListProtections(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tag protections: %w", err)
+	}
+
+	p := &tagProtection{namespace: namespace}
+	for _, protection := range protections {
+		re, err := compileProtection(protection.Pattern)
+		if err != nil {
+			log.Printf("Skipping invalid tag protection %q of namespace %s: %v", protection.Pattern, namespace, err)
+			continue
+		}
+		p.patterns = append(p.patterns, protection.Pattern)
+		p.compiled = append(p.compiled, re)
+	}
+	return p, nil
+}
+
+// match returns the pattern protecting a tag, empty if it isn't protected
+func (p *tagProtection) match(tagName string) string {
+	for i, re := range p.compiled {
+		if re.MatchString(tagName) {
+			return p.patterns[i]
+		}
+	}
+	return ""
+}
+
+// protected returns the references of the protected tags among tagNames
+func (p *tagProtection) protected(imageName string, tagNames []string) []string {
+	var refs []string
+	for _, tagName := range tagNames {
+		if p.match(tagName) != "" {
+			refs = append(refs, fmt.Sprintf("%s/%s:%s", p.namespace, imageName, tagName))
+		}
+	}
+	return refs
+}
+
+// checkTagProtection returns a *ProtectedTagError if any of the tags of an
+// image are protected
+func (s *SyncService) checkTagProtection(ctx context.Context, namespace, imageName string, tagNames []string) error {
+	protection, err := s.loadTagProtection(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if refs := protection.protected(imageName, tagNames); len(refs) > 0 {
+		return &ProtectedTagError{Tags: refs}
+	}
+	return nil
+}
+
+// recordViolation records that the sync found a protected tag pointing to a
+// different manifest. Failures are only logged so the tag is still synced.
+func (s *SyncService) recordViolation(ctx context.Context, namespace, imageName, tagName, previousDigest, digest string) {
+	protection, err := s.loadTagProtection(ctx, namespace)
+	if err != nil {
+		log.Printf("Failed to check protection of tag %s in %s/%s: %v", tagName, namespace, imageName, err)
+		return
+	}
+	pattern := protection.match(tagName)
+	if pattern == "" {
+		return
+	}
+
+	log.Printf("Protected tag %s in %s/%s was overwritten, it moved from %s to %s", tagName, namespace, imageName, previousDigest, digest)
+	violation := &models.ProtectionViolation{
+		Namespace:      namespace,
+		Image:          imageName,
+		Tag:            tagName,
+		Pattern:        pattern,
+		PreviousDigest: previousDigest,
+		Digest:         digest,
+		DetectedAt:     time.Now(),
+	}
+	if err := s.protectRepo.RecordViolation(ctx, violation); err != nil {
+		log.Printf("Failed to record protection violation of tag %s in %s/%s: %v", tagName, namespace, imageName, err)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ofkm/svelocker-ui/backend/internal/models"
+)
+
+// ErrRevisionNotFound is returned for revisions a tag never had
+var ErrRevisionNotFound = errors.New("tag revision not found")
+
+// ErrRevisionUnavailable is returned when a revision can't be rolled back to,
+// because its manifest wasn't recorded or the registry lost some of its blobs
+var ErrRevisionUnavailable = errors.New("tag revision can't be restored")
+
+// ListTagRevisions returns the digests a tag pointed to, newest first
+func (s *SyncService) ListTagRevisions(ctx context.Context, namespace, imageName, tagName string) ([]models.TagRevision, error) {
+	revisions, err := s.tagRepo.ListRevisions(ctx, namespace, imageName, tagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// TagRevisionAt returns the revision a tag pointed to at a point in time
+func (s *SyncService) TagRevisionAt(ctx context.Context, namespace, imageName, tagName string, at time.Time) (*models.TagRevision, error) {
+	revision, err := s.tagRepo.GetRevisionAt(ctx, namespace, imageName, tagName, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag revision: %w", err)
+	}
+	if revision == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return revision, nil
+}
+
+// RollbackTag points a tag back at the manifest of one of its revisions. The
+// manifest is only pushed if everything it references is still in the
+// repository, then the tag is synced to pick up the change. Protected tags
+// can't be rolled back, a *ProtectedTagError is returned for them.
+func (s *SyncService) RollbackTag(ctx context.Context, namespace, imageName, tagName string, revisionID uint) (*SyncResult, error) {
+	revision, err := s.tagRepo.GetRevision(ctx, namespace, imageName, tagName, revisionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag revision: %w", err)
+	}
+	if revision == nil {
+		return nil, ErrRevisionNotFound
+	}
+	if len(revision.Manifest) == 0 {
+		return nil, fmt.Errorf("%w: the manifest of %s was not recorded", ErrRevisionUnavailable, revision.Digest)
+	}
+
+	repoPath, err := s.repositoryPath(ctx, namespace, imageName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTagProtection(ctx, namespace, imageName, []string{tagName}); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkManifestBlobs(ctx, repoPath, revision.MediaType, revision.Manifest); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.registry.PutManifest(ctx, repoPath, tagName, revision.MediaType, revision.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to push manifest %s: %w", revision.Digest, err)
+	}
+
+	return s.SyncTag(ctx, models.SyncTriggerRollback, namespace, imageName, tagName)
+}
+
+// checkManifestBlobs makes sure the repository still has everything a
+// manifest references, the platform manifests of an index and the config and
+// layers of an image. Pushing it back would otherwise create a broken tag.
+func (s *SyncService) checkManifestBlobs(ctx context.Context, repoPath, mediaType string, raw []byte) error {
+	var manifest ManifestResponse
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("%w: invalid manifest: %v", ErrRevisionUnavailable, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = mediaType
+	}
+
+	if manifest.IsIndex() {
+		for _, m := range manifest.Manifests {
+			child, err := s.registry.GetManifest(ctx, repoPath, m.Digest)
+			if err != nil {
+				if isNotFound(err) {
+					return fmt.Errorf("%w: manifest %s is gone", ErrRevisionUnavailable, m.Digest)
+				}
+				return fmt.Errorf("failed to get manifest %s: %w", m.Digest, err)
+			}
+			if err := s.checkManifestBlobs(ctx, repoPath, child.MediaType, child.Raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, digest := range manifestBlobs(&manifest) {
+		exists, err := s.registry.BlobExists(ctx, repoPath, digest)
+		if err != nil {
+			return fmt.Errorf("failed to check blob %s: %w", digest, err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: blob %s is gone", ErrRevisionUnavailable, digest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// workerGroup runs functions in goroutines while a shared semaphore bounds
+// how many run at the same time. Several groups may share one semaphore.
+type workerGroup struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+func newWorkerGroup(sem chan struct{}) *workerGroup {
+	return &workerGroup{sem: sem}
+}
+
+// Go waits for a free slot and runs fn in a new goroutine. It returns the
+// context error without running fn if ctx is cancelled while waiting.
+func (g *workerGroup) Go(ctx context.Context, fn func()) error {
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			<-g.sem
+			g.wg.Done()
+		}()
+		fn()
+	}()
+
+	return nil
+}
+
+// Wait blocks until every function started by the group has returned
+func (g *workerGroup) Wait() {
+	g.wg.Wait()
+}
+
+// newSemaphore returns a semaphore with at least one slot
+func newSemaphore(size int) chan struct{} {
+	if size < 1 {
+		size = 1
+	}
+	return make(chan struct{}, size)
+}
@@ -39,6 +39,57 @@ func ExtractDockerfileFromHistory(history []struct {
 	return strings.Join(dockerCommands, "\n")
 }
 
+// Instructions a Dockerfile build step can be made by
+var dockerfileInstructions = map[string]bool{
+	"ADD": true, "ARG": true, "CMD": true, "COPY": true, "ENTRYPOINT": true,
+	"ENV": true, "EXPOSE": true, "FROM": true, "HEALTHCHECK": true, "LABEL": true,
+	"MAINTAINER": true, "ONBUILD": true, "RUN": true, "SHELL": true,
+	"STOPSIGNAL": true, "USER": true, "VOLUME": true, "WORKDIR": true,
+}
+
+// ParseHistoryInstruction splits the created_by of a history step into its
+// Dockerfile instruction and arguments. It understands both the legacy
+// builder ("/bin/sh -c #(nop)  ENV A=1", "/bin/sh -c make") and BuildKit
+// ("RUN /bin/sh -c make # buildkit"). The instruction is empty if unknown.
+func ParseHistoryInstruction(createdBy string) (instruction, command string) {
+	cmd := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(createdBy), "# buildkit"))
+
+	if rest, ok := cutShell(cmd); ok {
+		nop, isNop := strings.CutPrefix(rest, "#(nop)")
+		if !isNop {
+			// The legacy builder records RUN steps as the bare shell command
+			return "RUN", rest
+		}
+		cmd = strings.TrimSpace(nop)
+	}
+
+	word, args, _ := strings.Cut(cmd, " ")
+	instruction = strings.ToUpper(word)
+	if !dockerfileInstructions[instruction] {
+		return "", cmd
+	}
+
+	args = strings.TrimSpace(args)
+	if instruction == "RUN" {
+		if rest, ok := cutShell(args); ok {
+			args = rest
+		}
+	}
+	return instruction, args
+}
+
+// cutShell strips the shell a RUN step was executed with, including the
+// build arguments the builders put in front of it ("|2 A=1 B=2 /bin/sh -c")
+func cutShell(cmd string) (string, bool) {
+	if strings.HasPrefix(cmd, "|") {
+		if i := strings.Index(cmd, "/bin/sh -c "); i >= 0 {
+			cmd = cmd[i:]
+		}
+	}
+	rest, ok := strings.CutPrefix(cmd, "/bin/sh -c ")
+	return strings.TrimSpace(rest), ok
+}
+
 // ExtractAuthorFromLabels extracts the author from image labels, similar to TypeScript implementation
 func ExtractAuthorFromLabels(labels map[string]string, defaultAuthor string) string {
 
@@ -69,3 +120,49 @@ func ExtractAuthorFromLabels(labels map[string]string, defaultAuthor string) str
 
 	return "Unknown"
 }
+
+// ExtractDescription returns the OCI description of an image from its labels,
+// or else from the first annotations that have one
+func ExtractDescription(labels map[string]string, annotations ...map[string]string) string {
+	const key = "org.opencontainers.image.description"
+
+	if value := labels[key]; value != "" {
+		return value
+	}
+	for _, a := range annotations {
+		if value := a[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// RootNamespace holds images whose repository path has no namespace. Like
+// Docker Hub's "_", it can't be a path segment itself, so "nginx" and
+// "library/nginx" never end up as the same image.
+const RootNamespace = "_"
+
+// LegacyRootNamespace is where images without a namespace were stored before
+// RootNamespace. Links to it are redirected unless a registry path really
+// starts with it.
+const LegacyRootNamespace = "library"
+
+// SplitRepositoryPath splits a registry repository path into its namespace,
+// the first path segment, and the image name, which keeps all further
+// segments. "team/project/service" becomes "team" and "project/service".
+// JoinRepositoryPath turns the result back into the same path.
+func SplitRepositoryPath(repoPath string) (namespace, imageName string) {
+	if i := strings.IndexByte(repoPath, '/'); i >= 0 {
+		return repoPath[:i], repoPath[i+1:]
+	}
+	return RootNamespace, repoPath
+}
+
+// JoinRepositoryPath builds the registry path of an image from its namespace
+// and name, the reverse of SplitRepositoryPath
+func JoinRepositoryPath(namespace, imageName string) string {
+	if namespace == RootNamespace || namespace == "" {
+		return imageName
+	}
+	return namespace + "/" + imageName
+}
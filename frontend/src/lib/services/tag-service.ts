@@ -45,11 +45,14 @@ export class TagService {
 	}
 
 	/**
-	 * Delete a tag from a repository
+	 * Delete a tag from a repository. The registry deletes the tag's manifest,
+	 * so this fails with 409 when other tags share it unless confirmShared is set.
 	 */
-	async deleteTag(repoName: string, imageName: string, tagName: string): Promise<void> {
+	async deleteTag(repoName: string, imageName: string, tagName: string, confirmShared = false): Promise<void> {
 		try {
-			await axios.delete(`${this.baseUrl}/api/v1/repositories/${encodeURIComponent(repoName)}/images/${encodeURIComponent(imageName)}/tags/${encodeURIComponent(tagName)}`);
+			await axios.delete(`${this.baseUrl}/api/v1/repositories/${encodeURIComponent(repoName)}/images/${encodeURIComponent(imageName)}/tags/${encodeURIComponent(tagName)}`, {
+				params: confirmShared ? { confirm: 'true' } : undefined
+			});
 			this.logger.info(`Successfully deleted tag ${tagName} from image ${imageName} in repository ${repoName}`);
 		} catch (error) {
 			this.logger.error(`Failed to delete tag ${tagName}:`, error);
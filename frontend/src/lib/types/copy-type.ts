@@ -0,0 +1,40 @@
+export interface Registry {
+	ID: number;
+	name: string;
+	url: string;
+	username?: string;
+}
+
+export interface RegistryRequest {
+	url: string;
+	username?: string;
+	password?: string; // Empty keeps the stored password
+}
+
+export interface CopyRequest {
+	sourceRegistry?: string; // Defaults to "local"
+	source: string; // "namespace/image" or "namespace/image:tag"
+	targetRegistry: string; // "local" for the registry the UI manages
+	targetRepository?: string; // Defaults to the source repository
+}
+
+export type CopyJobStatus = 'queued' | 'running' | 'succeeded' | 'partial' | 'failed';
+
+export interface CopyJob {
+	ID: number;
+	sourceRegistry: string;
+	sourceRepository: string;
+	tag?: string; // Empty copies the whole repository
+	targetRegistry: string;
+	targetRepository: string;
+	status: CopyJobStatus;
+	tagsCopied: number;
+	tagsSkipped: number;
+	blobsCopied: number;
+	blobsSkipped: number;
+	bytesTransferred: number;
+	startedAt: string | null;
+	finishedAt: string | null;
+	error?: string;
+	errors?: string[];
+}
@@ -0,0 +1,44 @@
+export type DeletionPlanStatus = 'planned' | 'running' | 'succeeded' | 'partial' | 'failed';
+
+export type DeletionItemStatus = 'planned' | 'shared' | 'referenced' | 'protected' | 'deleted' | 'changed' | 'failed' | 'notFound';
+
+export interface DeletionPlanItem {
+	ID: number;
+	deletionPlanId: number;
+	namespace: string;
+	image: string;
+	repository: string;
+	digest?: string;
+	tags: string[];
+	sharedWith?: string[];
+	size: number;
+	status: DeletionItemStatus;
+	error?: string;
+}
+
+export interface DeletionPlan {
+	ID: number;
+	source: 'bulk' | 'retention';
+	ruleId?: number;
+	status: DeletionPlanStatus;
+	confirmShared: boolean;
+	bytesFreed: number;
+	startedAt?: string | null;
+	finishedAt?: string | null;
+	error?: string;
+	items?: DeletionPlanItem[];
+}
+
+export interface TagSelector {
+	repository?: string;
+	image?: string;
+	tagPattern?: string;
+	olderThan?: string;
+	largerThan?: number;
+}
+
+export interface BulkDeleteRequest {
+	tags?: string[];
+	selector?: TagSelector;
+	confirmShared?: boolean;
+}
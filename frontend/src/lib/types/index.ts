@@ -2,3 +2,9 @@ export * from './app-config-type';
 export * from './repository-type';
 export * from './image-type';
 export * from './tag-type';
+export * from './deletion-type';
+export * from './retention-type';
+export * from './protection-type';
+export * from './promotion-type';
+export * from './copy-type';
+export * from './mirror-type';
@@ -0,0 +1,33 @@
+export type MirrorStatus = 'succeeded' | 'partial' | 'failed';
+
+export interface MirrorRequest {
+	source: string; // Upstream repository with an optional tag glob like "docker.io/library/postgres:16*"
+	target: string; // Local repository like "mirror/postgres"
+	username?: string;
+	password?: string; // Empty keeps the stored password
+	cron?: string; // Defaults to "manual"
+}
+
+export interface MirroredTag {
+	ID: number;
+	mirrorId: number;
+	tag: string;
+	digest?: string; // Digest the tag was last mirrored at
+	mirroredAt: string | null;
+	error?: string; // Why the last attempt failed
+	failedAt: string | null;
+}
+
+export interface Mirror {
+	ID: number;
+	upstream: string;
+	tagPattern: string; // Empty mirrors every tag
+	target: string;
+	username?: string;
+	cron: string;
+	nextRun: string | null;
+	lastRun: string | null;
+	lastStatus?: MirrorStatus;
+	lastError?: string;
+	tags?: MirroredTag[];
+}
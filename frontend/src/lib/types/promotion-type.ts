@@ -0,0 +1,13 @@
+export interface PromoteRequest {
+	source: string; // "namespace/image:tag" or "namespace/image@sha256:..."
+	targetRepository?: string; // Defaults to the source repository
+	targetTag?: string; // Defaults to the source tag
+}
+
+export interface Promotion {
+	source: string;
+	target: string;
+	digest: string;
+	mountedBlobs: number;
+	copiedBlobs: number;
+}
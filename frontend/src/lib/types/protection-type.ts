@@ -0,0 +1,16 @@
+export interface TagProtection {
+	ID: number;
+	namespace: string;
+	pattern: string; // Regular expression the whole tag name must match
+}
+
+export interface ProtectionViolation {
+	ID: number;
+	namespace: string;
+	image: string;
+	tag: string;
+	pattern: string;
+	previousDigest: string;
+	digest: string;
+	detectedAt: string;
+}
@@ -0,0 +1,12 @@
+export interface RetentionRule {
+	ID: number;
+	namespace: string;
+	image: string; // Empty for the whole namespace
+	keepLatest: number;
+	olderThan: string;
+	tagPattern: string;
+	keepSemver: boolean;
+	cron: string; // Cron expression or "manual"
+	nextRun?: string | null;
+	lastRun?: string | null;
+}
@@ -8,11 +8,25 @@ export interface Tag {
 	digest: string;
 	createdAt: string;
 	metadata?: TagMetadata;
+	platforms?: TagPlatform[];
+}
+
+export interface TagPlatform {
+	ID?: number;
+	tagId: number;
+	os: string;
+	architecture: string;
+	variant?: string;
+	osVersion?: string;
+	manifestDigest: string;
+	size: number;
+	metadata?: TagMetadata;
 }
 
 export interface TagMetadata {
 	ID?: number;
 	tagId: number;
+	tagPlatformId?: number;
 	created: string;
 	os: string;
 	architecture: string;
@@ -29,6 +43,25 @@ export interface TagMetadata {
 	indexDigest?: string;
 	isOCI: boolean;
 	layers?: ImageLayer[];
+	history?: HistoryEntry[];
+	env?: string[];
+	user?: string;
+	volumes?: string[];
+	healthcheck?: Healthcheck;
+	stopSignal?: string;
+	shell?: string[];
+	onBuild?: string[];
+	labels?: Record<string, string>;
+}
+
+// Durations are in nanoseconds
+export interface Healthcheck {
+	test?: string[];
+	interval?: number;
+	timeout?: number;
+	startPeriod?: number;
+	startInterval?: number;
+	retries?: number;
 }
 
 export interface ImageLayer {
@@ -37,3 +70,28 @@ export interface ImageLayer {
 	size: number;
 	digest: string;
 }
+
+export interface HistoryEntry {
+	ID?: number;
+	tagMetadataId: number;
+	step: number;
+	created?: string;
+	createdBy: string;
+	instruction?: string;
+	command?: string;
+	author?: string;
+	comment?: string;
+	emptyLayer: boolean;
+	layerDigest?: string;
+	layerSize: number;
+}
+
+export interface TagRevision {
+	ID: number;
+	imageId: number;
+	tagName: string;
+	digest: string;
+	mediaType: string;
+	firstSeen: string;
+	replacedAt?: string;
+}
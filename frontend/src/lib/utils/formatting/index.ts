@@ -12,11 +12,11 @@ export function extractRepoName(fullRepoPath: string, defaultName: string = ''):
 /**
  * Extracts the namespace from a full repository name
  * @param fullName Full repository name (e.g., 'namespace/image' or 'image')
- * @returns Namespace string or 'library' for root-level images
+ * @returns Namespace string or '_' for root-level images
  */
 export function getNamespace(fullName: string): string {
 	if (!fullName?.includes('/')) {
-		return 'library'; // Use 'library' as default namespace like Docker Hub
+		return '_'; // Like Docker Hub's '_', so 'image' and 'library/image' stay apart
 	}
 	return fullName.split('/')[0];
 }
@@ -13,3 +13,12 @@ export function truncateText(text: string, maxLength: number): string {
 	if (!text) return '';
 	return text.length > maxLength ? `${text.substring(0, maxLength)}...` : text;
 }
+
+/**
+ * Builds the URL of a namespace, image or tag details page. Each segment is
+ * encoded so nested image names like "project/service" stay a single segment.
+ */
+export function detailsUrl(namespace: string, imageName?: string, tagName?: string): string {
+	const segments = [namespace, imageName, tagName].filter((segment): segment is string => !!segment);
+	return '/details/' + segments.map(encodeURIComponent).join('/');
+}
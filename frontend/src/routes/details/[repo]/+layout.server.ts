@@ -0,0 +1,21 @@
+import { redirect } from '@sveltejs/kit';
+import { RepositoryService } from '$lib/services/repository-service';
+
+// Images without a namespace moved from "library" to "_", the backend
+// redirects old links to them and this sends the browser along
+export async function load({ params, url }) {
+	if (params.repo !== 'library') {
+		return {};
+	}
+
+	let name = params.repo;
+	try {
+		name = (await RepositoryService.getInstance().getRepository(params.repo)).name;
+	} catch {
+		return {};
+	}
+	if (name !== params.repo) {
+		throw redirect(308, url.pathname.replace(/^\/details\/library/, `/details/${encodeURIComponent(name)}`) + url.search);
+	}
+	return {};
+}
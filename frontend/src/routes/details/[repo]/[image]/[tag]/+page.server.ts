@@ -1,6 +1,8 @@
 import type { PageServerLoad, Actions } from './$types';
 import { TagService } from '$lib/services/tag-service';
-import { error, redirect } from '@sveltejs/kit';
+import { error, fail, redirect } from '@sveltejs/kit';
+import axios from 'axios';
+import { detailsUrl } from '$lib/utils/ui';
 
 export const load: PageServerLoad = async ({ params }) => {
 	const tagService = TagService.getInstance();
@@ -27,10 +29,20 @@ export const actions: Actions = {
 			return { success: false, error: 'Please confirm deletion' };
 		}
 
-		// Call delete method in service
-		await tagService.deleteTag(params.repo, params.image, params.tag);
+		// Call delete method in service, other tags sharing the digest must be confirmed too
+		try {
+			await tagService.deleteTag(params.repo, params.image, params.tag, formData.get('confirmShared') === 'true');
+		} catch (err) {
+			if (axios.isAxiosError(err) && err.response) {
+				return fail(err.response.status, {
+					error: err.response.data?.error ?? 'Failed to delete tag',
+					sharedWith: (err.response.data?.sharedWith as string[] | undefined) ?? []
+				});
+			}
+			throw err;
+		}
 
 		// Throw a redirect instead of returning an object
-		throw redirect(303, `/details/${params.repo}/${params.image}`);
+		throw redirect(303, detailsUrl(params.repo, params.image));
 	}
 };